@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+// cronRunner keeps cron runs from overlapping. A run that is due while the
+// previous one is still running is skipped, or with the queue policy started
+// once the previous one finishes. At most one run waits, further ones are
+// skipped as they would only repeat it.
+type cronRunner struct {
+	queue   bool
+	running sync.Mutex
+	waiting atomic.Bool
+}
+
+func newCronRunner(overlap string) *cronRunner {
+	return &cronRunner{queue: overlap == "queue"}
+}
+
+func (r *cronRunner) run(job func()) {
+	if !r.running.TryLock() {
+		if !r.queue || !r.waiting.CompareAndSwap(false, true) {
+			logger.Warn("Previous sync is still running, skipping this run")
+			return
+		}
+
+		logger.Warn("Previous sync is still running, this run starts once it finishes")
+		r.running.Lock()
+		r.waiting.Store(false)
+	}
+	defer r.running.Unlock()
+
+	job()
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/lock"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/notification"
+	gitSync "github.com/AkashRajpurohit/git-sync/pkg/sync"
+	"github.com/spf13/cobra"
+)
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Repack, clean up and prune the repositories in the backup directory",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.InitLogger(logLevel)
+
+		cfg, ok := loadConfig()
+		if !ok {
+			return
+		}
+
+		runMaintenance(cmd.Context(), cfg)
+	},
+}
+
+// runMaintenance maintains the backup directory, which is locked for the
+// duration. It is skipped while another sync or maintenance holds the lock.
+func runMaintenance(ctx context.Context, cfg config.Config) {
+	backupLock, err := lock.Acquire(cfg.BackupDir)
+	if err != nil {
+		logger.Errorf("Skipping maintenance: %v", err)
+		return
+	}
+	defer func() {
+		if err := backupLock.Release(); err != nil {
+			logger.Warnf("Failed to release the lock on %s: %v", cfg.BackupDir, err)
+		}
+	}()
+
+	if report := maintainBackups(ctx, cfg); report != nil {
+		if err := notification.NotifyMaintenance(&cfg.Notification, report.Summary()); err != nil {
+			logger.Errorf("Failed to send notifications: %v", err)
+		}
+	}
+}
+
+// maintainBackups maintains the backup directory of cfg, which the caller has
+// locked. It returns nil when the maintenance could not run.
+func maintainBackups(ctx context.Context, cfg config.Config) *gitSync.MaintenanceReport {
+	report, err := gitSync.Maintain(ctx, cfg)
+	if err != nil {
+		logger.Errorf("Error maintaining repositories: %s", err)
+		return nil
+	}
+	return report
+}
+
+func init() {
+	rootCmd.AddCommand(maintenanceCmd)
+}
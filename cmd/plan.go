@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/plan"
+	"github.com/AkashRajpurohit/git-sync/pkg/raw"
+	"github.com/spf13/cobra"
+)
+
+var planOutput string
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show which repositories a sync would clone, update, skip or leave orphaned",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.InitStderrLogger(logLevel)
+
+		if planOutput != "table" && planOutput != "json" {
+			logger.Fatalf("Output format can only be `table` or `json`, got: %s", planOutput)
+		}
+
+		cfg, ok := loadConfig()
+		if !ok {
+			return
+		}
+
+		runPlan(cmd.Context(), cfg, planOutput)
+	},
+}
+
+// runPlan discovers repositories from all configured sources and prints what a
+// sync would do with them, without writing anything to the backup directory.
+func runPlan(ctx context.Context, cfg config.Config, output string) {
+	result := plan.New(cfg)
+
+	if platformClient := newPlatformClient(cfg); platformClient != nil {
+		logger.Infof("Planning sync for platform: %s", cfg.Platform)
+		platformPlan, err := plan.Build(ctx, cfg, platformClient)
+		if err != nil {
+			logger.Fatalf("Error planning platform repositories: %s", err)
+		}
+		result.Merge(platformPlan)
+	}
+
+	if len(cfg.RawGitURLs) > 0 {
+		rawPlan, err := plan.Build(ctx, cfg, raw.NewRawClient())
+		if err != nil {
+			logger.Fatalf("Error planning raw repositories: %s", err)
+		}
+		result.Merge(rawPlan)
+	}
+
+	if err := result.FindOrphans(); err != nil {
+		logger.Errorf("Error looking for orphaned repositories: %s", err)
+	}
+
+	var err error
+	if output == "json" {
+		err = result.WriteJSON(os.Stdout)
+	} else {
+		err = result.WriteTable(os.Stdout)
+	}
+	if err != nil {
+		logger.Fatalf("Error writing plan: %s", err)
+	}
+}
+
+func init() {
+	planCmd.Flags().StringVarP(&planOutput, "output", "o", "table", "output format (table, json)")
+	rootCmd.AddCommand(planCmd)
+}
@@ -1,17 +1,29 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/AkashRajpurohit/git-sync/pkg/azuredevops"
 	"github.com/AkashRajpurohit/git-sync/pkg/bitbucket"
+	"github.com/AkashRajpurohit/git-sync/pkg/bitbucketserver"
 	"github.com/AkashRajpurohit/git-sync/pkg/client"
 	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/filter"
 	"github.com/AkashRajpurohit/git-sync/pkg/forgejo"
 	"github.com/AkashRajpurohit/git-sync/pkg/github"
 	"github.com/AkashRajpurohit/git-sync/pkg/gitlab"
+	"github.com/AkashRajpurohit/git-sync/pkg/gogs"
+	"github.com/AkashRajpurohit/git-sync/pkg/lock"
 	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/notification"
+	"github.com/AkashRajpurohit/git-sync/pkg/plugin"
 	"github.com/AkashRajpurohit/git-sync/pkg/raw"
+	"github.com/AkashRajpurohit/git-sync/pkg/sourcehut"
+	gitSync "github.com/AkashRajpurohit/git-sync/pkg/sync"
 	"github.com/AkashRajpurohit/git-sync/pkg/telemetry"
 	ch "github.com/robfig/cron/v3"
 	"github.com/spf13/cobra"
@@ -22,60 +34,33 @@ var (
 	backupDir string
 	logLevel  string = "info"
 	cron      string
+	dryRun    bool
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "git-sync",
 	Short: "A tool to backup and sync your git repositories",
 	Run: func(cmd *cobra.Command, args []string) {
-		logger.InitLogger(logLevel)
-
-		configPath := config.GetConfigFile(cfgFile)
-		var cfg config.Config
-
-		// Check if config file exists
-		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			logger.Info("Config file not found, creating a new one...")
-			cfg = config.GetInitialConfig()
-
-			err = config.SaveConfig(cfg, cfgFile)
-			if err != nil {
-				logger.Fatal("Error in saving config file: ", err)
-			}
-			logger.Infof("Created new config file at: %s", configPath)
-			logger.Info("Please update the configuration according to your needs. See: https://github.com/AkashRajpurohit/git-sync/wiki/Configuration")
-			return
-		}
-
-		// Load existing config
-		cfg, err := config.LoadConfig(cfgFile)
-		if err != nil {
-			if _, ok := err.(*config.InvalidConfigError); ok {
-				logger.Errorf("Invalid configuration: %v", err)
-				logger.Info("Please check for correct configuration format at: https://github.com/AkashRajpurohit/git-sync/wiki/Configuration")
-				return
-			}
-			logger.Fatalf("Error loading config file: %v", err)
-		}
-
-		config.SetSensibleDefaults(&cfg)
-
-		// If backupDir option is passed in the command line, use that instead of the one in the config file
-		if backupDir != "" {
-			cfg.BackupDir = config.GetBackupDir(backupDir)
+		// The dry run prints its plan to stdout, the logs must not get in
+		// between
+		if dryRun {
+			logger.InitStderrLogger(logLevel)
+		} else {
+			logger.InitLogger(logLevel)
 		}
+		ctx := cmd.Context()
+		context.AfterFunc(ctx, func() {
+			logger.Warn("Shutdown requested, finishing running operations. Send the signal again to exit immediately.")
+		})
 
-		// If cron option is passed in the command line, use that instead of the one in the config file
-		if cron != "" {
-			cfg.Cron = cron
+		cfg, ok := loadConfig()
+		if !ok {
+			return
 		}
 
-		logger.Info("Config loaded from: ", configPath)
-		logger.Debug("Validating config ⏳")
-
-		err = config.ValidateConfig(cfg)
-		if err != nil {
-			logger.Fatalf("Error validating config: %s", err)
+		if dryRun {
+			runPlan(ctx, cfg, "table")
+			return
 		}
 
 		telemetry.Init(cfg.Telemetry)
@@ -84,85 +69,223 @@ var rootCmd = &cobra.Command{
 		// Create backup directory if it doesn't exist
 		os.MkdirAll(cfg.BackupDir, os.ModePerm)
 
-		var platformClient client.Client
+		platformClient := newPlatformClient(cfg)
 		var hasRawURLs bool = len(cfg.RawGitURLs) > 0
 
-		// Only initialize platform client if raw URLs are not provided or if both are needed
-		if !hasRawURLs || (cfg.Username != "" && len(cfg.Tokens) > 0) {
-			switch cfg.Platform {
-			case "github":
-				platformClient = github.NewGitHubClient(cfg.Tokens)
-			case "gitlab":
-				platformClient = gitlab.NewGitlabClient(cfg.Server, cfg.Tokens)
-			case "bitbucket":
-				platformClient = bitbucket.NewBitbucketClient(cfg.Username, cfg.Tokens)
-			case "forgejo", "gitea":
-				// Forgejo and Gitea have same API, so we can use the same client
-				platformClient = forgejo.NewForgejoClient(cfg.Server, cfg.Tokens)
-			default:
-				if !hasRawURLs {
-					logger.Fatalf("Platform %s not supported", cfg.Platform)
-				}
-			}
-		}
-
-		logger.Info("✅ Valid config found")
 		if platformClient != nil {
 			logger.Infof("Using Platform: %s", cfg.Platform)
 		}
+
 		if hasRawURLs {
 			logger.Infof("Found %d raw git URLs to sync", len(cfg.RawGitURLs))
 		}
 
 		if cfg.Cron != "" {
+			runner := newCronRunner(cfg.CronOverlap)
 			c := ch.New()
 			_, err := c.AddFunc(cfg.Cron, func() {
-				// First sync platform repositories if configured
-				if platformClient != nil {
-					if err := platformClient.Sync(cfg); err != nil {
-						logger.Errorf("Error syncing platform repositories: %s", err)
-					}
-				}
-
-				// Then sync raw git URLs if any
-				if hasRawURLs {
-					rawClient := raw.NewRawClient()
-					if err := rawClient.Sync(cfg); err != nil {
-						logger.Errorf("Error syncing raw repositories: %s", err)
-					}
-				}
+				runner.run(func() {
+					runSync(ctx, cfg, platformClient)
+				})
 			})
 
 			if err != nil {
 				logger.Fatalf("Error adding cron job: %s", err)
 			}
 
+			if cfg.Maintenance.Enabled && cfg.Maintenance.Cron != "" {
+				_, err := c.AddFunc(cfg.Maintenance.Cron, func() {
+					runMaintenance(ctx, cfg)
+				})
+				if err != nil {
+					logger.Fatalf("Error adding maintenance cron job: %s", err)
+				}
+			}
+
 			c.Start()
 			logger.Infof("Cron job scheduled to run at: %s", cfg.Cron)
+			if cfg.Maintenance.Enabled && cfg.Maintenance.Cron != "" {
+				logger.Infof("Maintenance scheduled to run at: %s", cfg.Maintenance.Cron)
+			}
 
-			// Wait indefinitely
-			select {}
+			// Run until a shutdown is requested, then wait for a running sync to wind down
+			<-ctx.Done()
+			<-c.Stop().Done()
+			logger.Info("Shutdown complete")
 		} else {
-			// First sync platform repositories if configured
-			if platformClient != nil {
-				if err := platformClient.Sync(cfg); err != nil {
-					logger.Errorf("Error syncing platform repositories: %s", err)
-				}
-			}
+			runSync(ctx, cfg, platformClient)
+		}
+	},
+}
 
-			// Then sync raw git URLs if any
-			if hasRawURLs {
-				rawClient := raw.NewRawClient()
-				if err := rawClient.Sync(cfg); err != nil {
-					logger.Errorf("Error syncing raw repositories: %s", err)
-				}
+// runSync syncs the platform repositories and then the raw git URLs, and
+// maintains the backups afterwards when maintenance is enabled without a
+// schedule of its own, reporting it in the summary of the last sync. The backup directory is locked for the duration, so a
+// run is skipped while another git-sync process is syncing the same directory.
+func runSync(ctx context.Context, cfg config.Config, platformClient client.Client) {
+	backupLock, err := lock.Acquire(cfg.BackupDir)
+	if err != nil {
+		logger.Errorf("Skipping sync: %v", err)
+		return
+	}
+	defer func() {
+		if err := backupLock.Release(); err != nil {
+			logger.Warnf("Failed to release the lock on %s: %v", cfg.BackupDir, err)
+		}
+	}()
+
+	syncer, err := gitSync.NewSyncer(cfg)
+	if err != nil {
+		logger.Errorf("Skipping sync: %v", err)
+		return
+	}
+	defer syncer.Close()
+
+	// Maintenance following the sync is reported along with it, so the
+	// notifications wait for it
+	maintain := cfg.Maintenance.Enabled && cfg.Maintenance.Cron == ""
+	var summaries []*notification.SyncSummary
+	if maintain {
+		syncer.Notify = func(summary *notification.SyncSummary) error {
+			summaries = append(summaries, summary)
+			return nil
+		}
+	}
+
+	// First sync platform repositories if configured
+	if platformClient != nil {
+		if _, err := syncer.Sync(ctx, cfg.Platform, platformClient); err != nil {
+			logger.Errorf("Error syncing platform repositories: %s", err)
+		}
+	}
+
+	// Then sync raw git URLs if any
+	if len(cfg.RawGitURLs) > 0 {
+		if _, err := syncer.Sync(ctx, "raw", raw.NewRawClient()); err != nil {
+			logger.Errorf("Error syncing raw repositories: %s", err)
+		}
+	}
+
+	if maintain && ctx.Err() == nil {
+		if report := maintainBackups(ctx, cfg); report != nil {
+			if len(summaries) > 0 {
+				summaries[len(summaries)-1].Maintenance = report.Summary()
+			} else if err := notification.NotifyMaintenance(&cfg.Notification, report.Summary()); err != nil {
+				logger.Errorf("Failed to send notifications: %v", err)
 			}
 		}
-	},
+	}
+	for _, summary := range summaries {
+		if err := notification.NotifyAll(&cfg.Notification, summary); err != nil {
+			logger.Errorf("Failed to send notifications: %v", err)
+		}
+	}
+}
+
+// loadConfig loads and validates the config file, creating an initial one if it
+// does not exist yet. It returns false when there is nothing more to do.
+func loadConfig() (config.Config, bool) {
+	configPath := config.GetConfigFile(cfgFile)
+	var cfg config.Config
+
+	// Check if config file exists
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		logger.Info("Config file not found, creating a new one...")
+		cfg = config.GetInitialConfig()
+		err = config.SaveConfig(cfg, cfgFile)
+		if err != nil {
+			logger.Fatal("Error in saving config file: ", err)
+		}
+		logger.Infof("Created new config file at: %s", configPath)
+		logger.Info("Please update the configuration according to your needs. See: https://github.com/AkashRajpurohit/git-sync/wiki/Configuration")
+		return cfg, false
+	}
+
+	// Load existing config
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		if _, ok := err.(*config.InvalidConfigError); ok {
+			logger.Errorf("Invalid configuration: %v", err)
+			logger.Info("Please check for correct configuration format at: https://github.com/AkashRajpurohit/git-sync/wiki/Configuration")
+			return cfg, false
+		}
+		logger.Fatalf("Error loading config file: %v", err)
+	}
+
+	config.SetSensibleDefaults(&cfg, config.ConfiguredKeys())
+
+	// If backupDir option is passed in the command line, use that instead of the one in the config file
+	if backupDir != "" {
+		cfg.BackupDir = config.GetBackupDir(backupDir)
+	}
+
+	// If cron option is passed in the command line, use that instead of the one in the config file
+	if cron != "" {
+		cfg.Cron = cron
+	}
+
+	logger.Info("Config loaded from: ", configPath)
+	logger.Debug("Validating config ⏳")
+
+	err = config.ValidateConfig(cfg)
+	if err == nil {
+		err = filter.Validate(cfg)
+	}
+	if err != nil {
+		logger.Fatalf("Error validating config: %s", err)
+	}
+
+	logger.Info("✅ Valid config found")
+	return cfg, true
+}
+
+// newPlatformClient returns the client for the configured platform, or nil when
+// only raw git URLs are being synced
+func newPlatformClient(cfg config.Config) client.Client {
+	hasRawURLs := len(cfg.RawGitURLs) > 0
+
+	// Only initialize platform client if raw URLs are not provided or if both are needed
+	if hasRawURLs && cfg.Platform != "exec" && (cfg.Username == "" || len(cfg.Tokens) == 0) {
+		return nil
+	}
+
+	switch cfg.Platform {
+	case "github":
+		return github.NewGitHubClient(cfg.Tokens)
+	case "gitlab":
+		return gitlab.NewGitlabClient(cfg.Server, cfg.Tokens)
+	case "bitbucket":
+		return bitbucket.NewBitbucketClient(cfg.Username, cfg.Tokens)
+	case "bitbucket-server":
+		return bitbucketserver.NewBitbucketServerClient(cfg.Server, cfg.Tokens)
+	case "azure-devops":
+		return azuredevops.NewAzureDevOpsClient(cfg.Server, cfg.Tokens)
+	case "gogs":
+		return gogs.NewGogsClient(cfg.Server, cfg.Tokens)
+	case "sourcehut":
+		return sourcehut.NewSourcehutClient(cfg.Server, cfg.Tokens)
+	case "exec":
+		return plugin.NewExecClient(cfg)
+	case "forgejo", "gitea":
+		// Forgejo and Gitea have same API, so we can use the same client
+		return forgejo.NewForgejoClient(cfg.Server, cfg.Tokens)
+	default:
+		if !hasRawURLs {
+			logger.Fatalf("Platform %s not supported", cfg.Platform)
+		}
+	}
+
+	return nil
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// SIGINT and SIGTERM stop new work while running operations get
+	// shutdown_timeout to finish. A second signal exits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -173,4 +296,5 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&backupDir, "backup-dir", "", "directory to backup repositories (default is $HOME/git-backups)")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level (debug, info, warn, error, fatal)")
 	rootCmd.PersistentFlags().StringVar(&cron, "cron", "", "cron expression to run the sync job periodically")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what a sync would do without touching the backup directory")
 }
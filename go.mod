@@ -14,6 +14,8 @@ require (
 	github.com/xanzy/go-gitlab v0.115.0
 	go.uber.org/zap v1.27.1
 	golang.org/x/oauth2 v0.35.0
+	golang.org/x/sys v0.32.0
+	golang.org/x/time v0.8.0
 )
 
 require (
@@ -41,7 +43,5 @@ require (
 	go.yaml.in/yaml/v3 v3.0.4 // indirect
 	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/net v0.39.0 // indirect
-	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.28.0 // indirect
-	golang.org/x/time v0.8.0 // indirect
 )
@@ -0,0 +1,322 @@
+package azuredevops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/issues"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/token"
+)
+
+const (
+	apiVersion = "7.1"
+	// profileURL lists the organizations of a PAT on Azure DevOps Services
+	profileURL = "https://app.vssps.visualstudio.com/_apis"
+)
+
+// AzureDevOpsClient backs up Git repositories of Azure DevOps organizations.
+// Repositories are stored as <org>/<project>/<repo>, while project wikis and
+// work items are stored once per project under <org>/<project>.
+type AzureDevOpsClient struct {
+	tokenManager *token.Manager
+	serverConfig config.Server
+	httpClient   *http.Client
+}
+
+type project struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type repository struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	RemoteURL  string  `json:"remoteUrl"`
+	Size       int64   `json:"size"`
+	IsDisabled bool    `json:"isDisabled"`
+	IsFork     bool    `json:"isFork"`
+	Project    project `json:"project"`
+}
+
+type wiki struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`      // projectWiki or codeWiki
+	RemoteURL string `json:"remoteUrl"` // the web page, not a clone URL
+}
+
+// orgProject is a project together with its organization, repositories and
+// the clone URL of its project wiki, if any
+type orgProject struct {
+	org     string
+	project project
+	repos   []*repository
+	wikiURL string
+}
+
+func (p orgProject) owner() string {
+	return p.org + "/" + p.project.Name
+}
+
+type listResponse[T any] struct {
+	Count int `json:"count"`
+	Value []T `json:"value"`
+}
+
+func NewAzureDevOpsClient(serverConfig config.Server, tokens []string) *AzureDevOpsClient {
+	return &AzureDevOpsClient{
+		tokenManager: token.NewManager(tokens),
+		serverConfig: serverConfig,
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (c *AzureDevOpsClient) GetTokenManager() *token.Manager {
+	return c.tokenManager
+}
+
+// ListRepositories returns the repositories of every project followed by the
+// projects themselves as containers for their wiki and work items
+func (c *AzureDevOpsClient) ListRepositories(ctx context.Context, cfg config.Config) ([]client.Repository, error) {
+	projects, err := c.listProjects(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []client.Repository
+	for _, p := range projects {
+		for _, repo := range p.repos {
+			repos = append(repos, client.Repository{
+				ID:       repo.ID,
+				Owner:    p.owner(),
+				Name:     repo.Name,
+				CloneURL: repo.RemoteURL,
+				Fork:     repo.IsFork,
+				SizeKB:   repo.Size / 1024,
+			})
+		}
+	}
+
+	for _, p := range projects {
+		repos = append(repos, client.Repository{
+			ID:        p.project.ID,
+			Owner:     p.org,
+			Name:      p.project.Name,
+			WikiURL:   p.wikiURL,
+			HasWiki:   p.wikiURL != "",
+			HasIssues: true,
+			Container: true,
+		})
+	}
+
+	return repos, nil
+}
+
+// FetchIssues exports the work items of a project container as issues
+func (c *AzureDevOpsClient) FetchIssues(ctx context.Context, repo client.Repository, since time.Time, incremental bool) ([]issues.Issue, error) {
+	return c.fetchWorkItems(ctx, repo.Owner, repo.Name, since, incremental)
+}
+
+// listProjects enumerates every project of the organizations listed in orgs,
+// or of all organizations the token is a member of, with their repositories
+// and project wiki
+func (c *AzureDevOpsClient) listProjects(ctx context.Context, cfg config.Config) ([]orgProject, error) {
+	orgs := cfg.Orgs
+	if len(orgs) == 0 {
+		var err error
+		if orgs, err = c.listOrganizations(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var allProjects []orgProject
+	for _, org := range orgs {
+		logger.Debugf("Fetching list of projects for organization %s ⏳", org)
+		projects, err := c.listOrgProjects(ctx, org)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range projects {
+			logger.Debugf("Fetching list of repositories for %s/%s ⏳", org, p.Name)
+			var repos listResponse[*repository]
+			if _, err := c.get(ctx, c.orgURL(org, p.Name, "git/repositories"), nil, &repos); err != nil {
+				return nil, err
+			}
+
+			proj := orgProject{org: org, project: p}
+			for _, repo := range repos.Value {
+				// Disabled repositories cannot be cloned
+				if repo.IsDisabled {
+					logger.Debugf("Skipping disabled repository %s/%s/%s", org, p.Name, repo.Name)
+					continue
+				}
+				proj.repos = append(proj.repos, repo)
+			}
+
+			if cfg.WikiEnabled() {
+				var wikis listResponse[wiki]
+				if _, err := c.get(ctx, c.orgURL(org, p.Name, "wiki/wikis"), nil, &wikis); err != nil {
+					return nil, err
+				}
+				// Code wikis are published from a branch of a repository that is
+				// already backed up, so only the project wiki is cloned separately.
+				// Its remoteUrl is the web page of the wiki, the wiki repository
+				// is cloned from _git like any other.
+				for _, w := range wikis.Value {
+					if w.Type == "projectWiki" {
+						proj.wikiURL = c.gitURL(org, p.Name, w.Name)
+					}
+				}
+			}
+
+			allProjects = append(allProjects, proj)
+		}
+	}
+
+	return allProjects, nil
+}
+
+func (c *AzureDevOpsClient) listOrgProjects(ctx context.Context, org string) ([]project, error) {
+	var allProjects []project
+	continuation := ""
+
+	for {
+		query := url.Values{"$top": {"100"}}
+		if continuation != "" {
+			query.Set("continuationToken", continuation)
+		}
+
+		var projects listResponse[project]
+		header, err := c.get(ctx, c.orgURL(org, "", "projects"), query, &projects)
+		if err != nil {
+			return nil, err
+		}
+		allProjects = append(allProjects, projects.Value...)
+
+		continuation = header.Get("x-ms-continuationtoken")
+		if continuation == "" {
+			break
+		}
+	}
+
+	return allProjects, nil
+}
+
+// listOrganizations returns the organizations the token owner is a member of.
+// This is only available on Azure DevOps Services, not on Azure DevOps Server.
+func (c *AzureDevOpsClient) listOrganizations(ctx context.Context) ([]string, error) {
+	logger.Debug("Fetching list of organizations ⏳")
+
+	var profile struct {
+		ID string `json:"id"`
+	}
+	if _, err := c.get(ctx, profileURL+"/profile/profiles/me", nil, &profile); err != nil {
+		return nil, fmt.Errorf("failed to fetch profile, set orgs to list organizations explicitly: %w", err)
+	}
+
+	var accounts listResponse[struct {
+		AccountName string `json:"accountName"`
+	}]
+	if _, err := c.get(ctx, profileURL+"/accounts", url.Values{"memberId": {profile.ID}}, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	orgs := make([]string, 0, len(accounts.Value))
+	for _, a := range accounts.Value {
+		orgs = append(orgs, a.AccountName)
+	}
+
+	return orgs, nil
+}
+
+// orgURL builds the URL of an API resource of an organization, optionally
+// scoped to a project
+// gitURL returns the clone URL of the repository repoName of a project
+func (c *AzureDevOpsClient) gitURL(org, projectName, repoName string) string {
+	return fmt.Sprintf("%s://%s/%s/%s/_git/%s", c.serverConfig.Protocol, strings.TrimSuffix(c.serverConfig.Domain, "/"),
+		url.PathEscape(org), url.PathEscape(projectName), url.PathEscape(repoName))
+}
+
+func (c *AzureDevOpsClient) orgURL(org, projectName, resource string) string {
+	base := fmt.Sprintf("%s://%s/%s", c.serverConfig.Protocol, strings.TrimSuffix(c.serverConfig.Domain, "/"), url.PathEscape(org))
+	if projectName != "" {
+		base += "/" + url.PathEscape(projectName)
+	}
+	return base + "/_apis/" + resource
+}
+
+func (c *AzureDevOpsClient) get(ctx context.Context, reqURL string, query url.Values, out interface{}) (http.Header, error) {
+	return c.do(ctx, http.MethodGet, reqURL, query, nil, out)
+}
+
+// do sends an API request authenticated with the next token, trying the other
+// tokens when it fails
+func (c *AzureDevOpsClient) do(ctx context.Context, method, reqURL string, query url.Values, body interface{}, out interface{}) (http.Header, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	if query.Get("api-version") == "" {
+		query.Set("api-version", apiVersion)
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %v", err)
+		}
+	}
+
+	var header http.Header
+	err := client.WithTokens(ctx, c.tokenManager, func(ctx context.Context) error {
+		var err error
+		header, err = c.doOnce(ctx, method, reqURL+"?"+query.Encode(), payload, out)
+		return err
+	})
+	return header, err
+}
+
+func (c *AzureDevOpsClient) doOnce(ctx context.Context, method, reqURL string, payload []byte, out interface{}) (http.Header, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	// Personal access tokens are sent as the password with an empty username
+	req.SetBasicAuth("", c.tokenManager.GetNextToken())
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %v", req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	if err := client.CheckResponse(resp, req.URL.Path); err != nil {
+		return nil, err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return nil, fmt.Errorf("failed to decode response from %s: %v", req.URL.Path, err)
+	}
+
+	return resp.Header, nil
+}
@@ -0,0 +1,192 @@
+package azuredevops
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/issues"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+// workItemBatchSize is the maximum number of ids accepted by the work items API
+const workItemBatchSize = 200
+
+type identity struct {
+	DisplayName string `json:"displayName"`
+	UniqueName  string `json:"uniqueName"`
+	URL         string `json:"url"`
+}
+
+type workItem struct {
+	ID     int `json:"id"`
+	Fields struct {
+		Title         string     `json:"System.Title"`
+		Description   string     `json:"System.Description"`
+		State         string     `json:"System.State"`
+		WorkItemType  string     `json:"System.WorkItemType"`
+		Tags          string     `json:"System.Tags"`
+		IterationPath string     `json:"System.IterationPath"`
+		CreatedBy     identity   `json:"System.CreatedBy"`
+		AssignedTo    *identity  `json:"System.AssignedTo"`
+		CreatedDate   time.Time  `json:"System.CreatedDate"`
+		ChangedDate   time.Time  `json:"System.ChangedDate"`
+		ClosedDate    *time.Time `json:"Microsoft.VSTS.Common.ClosedDate"`
+	} `json:"fields"`
+	Links struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"_links"`
+}
+
+type workItemComment struct {
+	ID           int64     `json:"id"`
+	Text         string    `json:"text"`
+	CreatedBy    identity  `json:"createdBy"`
+	CreatedDate  time.Time `json:"createdDate"`
+	ModifiedDate time.Time `json:"modifiedDate"`
+	URL          string    `json:"url"`
+}
+
+// fetchWorkItems exports the work items of a project as issues. When
+// incremental is set only work items changed since the last sync are fetched.
+func (c *AzureDevOpsClient) fetchWorkItems(ctx context.Context, org, projectName string, since time.Time, incremental bool) ([]issues.Issue, error) {
+	projectFullName := fmt.Sprintf("%s/%s", org, projectName)
+
+	query := "SELECT [System.Id] FROM WorkItems WHERE [System.TeamProject] = @project"
+	if incremental {
+		query += fmt.Sprintf(" AND [System.ChangedDate] > '%s'", since.UTC().Format(time.RFC3339))
+		logger.Debugf("Incremental fetch for %s (work items changed since %s)", projectFullName, since.Format(time.RFC3339))
+	} else {
+		logger.Debugf("Full fetch for %s ⏳", projectFullName)
+	}
+	query += " ORDER BY [System.ChangedDate] ASC"
+
+	var result struct {
+		WorkItems []struct {
+			ID int `json:"id"`
+		} `json:"workItems"`
+	}
+	_, err := c.do(ctx, http.MethodPost, c.orgURL(org, projectName, "wit/wiql"), url.Values{"timePrecision": {"true"}}, map[string]string{"query": query}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(result.WorkItems))
+	for _, w := range result.WorkItems {
+		ids = append(ids, strconv.Itoa(w.ID))
+	}
+
+	logger.Debugf("Found %d work items for %s", len(ids), projectFullName)
+
+	allIssues := make([]issues.Issue, 0, len(ids))
+	for start := 0; start < len(ids); start += workItemBatchSize {
+		end := min(start+workItemBatchSize, len(ids))
+
+		var batch listResponse[workItem]
+		query := url.Values{"ids": {strings.Join(ids[start:end], ",")}, "$expand": {"links"}}
+		if _, err := c.get(ctx, c.orgURL(org, projectName, "wit/workitems"), query, &batch); err != nil {
+			return nil, err
+		}
+
+		for _, w := range batch.Value {
+			issue := convertWorkItem(w)
+
+			comments, err := c.fetchWorkItemComments(ctx, org, projectName, w.ID)
+			if err != nil {
+				logger.Warnf("Failed to fetch comments for work item #%d in %s: %v", w.ID, projectFullName, err)
+			} else {
+				issue.Comments = comments
+			}
+
+			allIssues = append(allIssues, issue)
+		}
+	}
+
+	logger.Debugf("Fetched %d work items for %s", len(allIssues), projectFullName)
+	return allIssues, nil
+}
+
+func (c *AzureDevOpsClient) fetchWorkItemComments(ctx context.Context, org, projectName string, id int) ([]issues.Comment, error) {
+	var allComments []issues.Comment
+	continuation := ""
+
+	for {
+		// The comments API is only available as a preview version
+		query := url.Values{"api-version": {apiVersion + "-preview.4"}}
+		if continuation != "" {
+			query.Set("continuationToken", continuation)
+		}
+
+		var result struct {
+			Comments          []workItemComment `json:"comments"`
+			ContinuationToken string            `json:"continuationToken"`
+		}
+		if _, err := c.get(ctx, c.orgURL(org, projectName, fmt.Sprintf("wit/workItems/%d/comments", id)), query, &result); err != nil {
+			return nil, err
+		}
+
+		for _, comment := range result.Comments {
+			allComments = append(allComments, issues.Comment{
+				ID:        comment.ID,
+				Body:      comment.Text,
+				Author:    toUser(comment.CreatedBy),
+				URL:       comment.URL,
+				CreatedAt: comment.CreatedDate,
+				UpdatedAt: comment.ModifiedDate,
+			})
+		}
+
+		if result.ContinuationToken == "" {
+			break
+		}
+		continuation = result.ContinuationToken
+	}
+
+	return allComments, nil
+}
+
+func convertWorkItem(w workItem) issues.Issue {
+	issue := issues.Issue{
+		Number:    w.ID,
+		Title:     w.Fields.Title,
+		Body:      w.Fields.Description,
+		State:     w.Fields.State,
+		Author:    toUser(w.Fields.CreatedBy),
+		Milestone: w.Fields.IterationPath,
+		URL:       w.Links.HTML.Href,
+		CreatedAt: w.Fields.CreatedDate,
+		UpdatedAt: w.Fields.ChangedDate,
+		ClosedAt:  w.Fields.ClosedDate,
+	}
+
+	// The work item type is kept as a label since issues have no such field
+	labels := []string{w.Fields.WorkItemType}
+	for _, tag := range strings.Split(w.Fields.Tags, ";") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			labels = append(labels, tag)
+		}
+	}
+	issue.Labels = labels
+
+	assignees := make([]issues.User, 0, 1)
+	if w.Fields.AssignedTo != nil {
+		assignees = append(assignees, toUser(*w.Fields.AssignedTo))
+	}
+	issue.Assignees = assignees
+
+	return issue
+}
+
+func toUser(i identity) issues.User {
+	login := i.UniqueName
+	if login == "" {
+		login = i.DisplayName
+	}
+	return issues.User{Login: login, URL: i.URL}
+}
@@ -1,10 +1,13 @@
 package bitbucket
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
 	"github.com/AkashRajpurohit/git-sync/pkg/config"
-	"github.com/AkashRajpurohit/git-sync/pkg/helpers"
 	"github.com/AkashRajpurohit/git-sync/pkg/logger"
-	gitSync "github.com/AkashRajpurohit/git-sync/pkg/sync"
 	"github.com/AkashRajpurohit/git-sync/pkg/token"
 	bb "github.com/ktrysmt/go-bitbucket"
 )
@@ -29,72 +32,85 @@ func (c *BitbucketClient) createClient() *bb.Client {
 	return bb.NewBasicAuth(c.username, c.tokenManager.GetNextToken())
 }
 
-func (c *BitbucketClient) Sync(cfg config.Config) error {
-	repos, err := c.getRepos(cfg)
+// workspaceRepo pairs a repository with the workspace it was listed from
+type workspaceRepo struct {
+	workspace string
+	repo      *bb.Repository
+}
+
+func (c *BitbucketClient) ListRepositories(ctx context.Context, cfg config.Config) ([]client.Repository, error) {
+	repos, err := c.listAllRepos(ctx, cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	gitSync.LogRepoCount(len(repos), cfg.Platform)
-
-	gitSync.SyncWithConcurrency(cfg, repos, func(repo *bb.Repository) {
-		gitSync.CloneOrUpdateRepo(cfg.Workspace, repo.Name, cfg)
-		if cfg.IncludeWiki && repo.Has_wiki {
-			gitSync.SyncWiki(cfg.Workspace, repo.Name, cfg)
-		}
-	})
+	allRepos := make([]client.Repository, 0, len(repos))
+	for _, r := range repos {
+		allRepos = append(allRepos, toRepository(r.workspace, r.repo))
+	}
 
-	gitSync.LogSyncSummary(&cfg)
-	return nil
+	return allRepos, nil
 }
 
-func (c *BitbucketClient) getRepos(cfg config.Config) ([]*bb.Repository, error) {
-	client := c.createClient()
-	opt := &bb.RepositoriesOptions{
-		Owner: cfg.Workspace,
-		Page:  &[]int{1}[0],
-	}
+// listAllRepos returns the repositories of the configured workspace followed
+// by those of the additional users and orgs, which are workspaces on Bitbucket
+func (c *BitbucketClient) listAllRepos(ctx context.Context, cfg config.Config) ([]workspaceRepo, error) {
+	seen := make(map[string]bool)
+	var allRepos []workspaceRepo
 
-	var allRepos []*bb.Repository
-	for {
-		repos, err := client.Repositories.ListForAccount(opt)
-		if err != nil {
-			logger.Debugf("Error with current token, trying next token: %v", err)
-			client = c.createClient()
+	workspaces := append([]string{cfg.Workspace}, cfg.Users...)
+	workspaces = append(workspaces, cfg.Orgs...)
+	for i, workspace := range workspaces {
+		if seen[workspace] {
 			continue
 		}
+		seen[workspace] = true
 
-		var reposToInclude []*bb.Repository
+		if i > 0 {
+			logger.Debugf("Fetching list of repositories for workspace %s ⏳", workspace)
+		}
 
-		for _, repo := range repos.Items {
-			repoName := repo.Name
+		repos, err := c.listRepos(ctx, workspace)
+		if err != nil {
+			return nil, err
+		}
 
-			if len(cfg.IncludeRepos) > 0 {
-				if helpers.IsIncludedInList(cfg.IncludeRepos, repoName) {
-					logger.Debug("[include_repos] Repo included: ", repoName)
-					reposToInclude = append(reposToInclude, &repo)
-				}
+		for _, repo := range repos {
+			allRepos = append(allRepos, workspaceRepo{workspace: workspace, repo: repo})
+		}
+	}
 
-				continue
-			}
+	return allRepos, nil
+}
 
-			if len(cfg.ExcludeRepos) > 0 {
-				if helpers.IsIncludedInList(cfg.ExcludeRepos, repoName) {
-					logger.Debug("[exclude_repos] Repo excluded: ", repoName)
-					continue
-				}
-			}
+// listRepos returns every repository in the workspace before any filtering.
+// Each page is a request of its own that tries every token before giving up,
+// see client.WithTokens. The Bitbucket SDK does not accept a context, so
+// cancellation is only noticed between requests.
+func (c *BitbucketClient) listRepos(ctx context.Context, workspace string) ([]*bb.Repository, error) {
+	opt := &bb.RepositoriesOptions{
+		Owner: workspace,
+		Page:  &[]int{1}[0],
+	}
 
-			if !cfg.IncludeForks && repo.Parent != nil {
-				logger.Debug("[include_forks] Repo excluded: ", repoName)
-				continue
+	var allRepos []*bb.Repository
+	for {
+		var repos *bb.RepositoriesRes
+		err := client.WithTokens(ctx, c.tokenManager, func(ctx context.Context) error {
+			if err := ctx.Err(); err != nil {
+				return err
 			}
-
-			logger.Debug("Repo included: ", repoName)
-			reposToInclude = append(reposToInclude, &repo)
+			var err error
+			repos, err = c.createClient().Repositories.ListForAccount(opt)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories for %s: %w", workspace, err)
 		}
 
-		allRepos = append(allRepos, reposToInclude...)
+		for i := range repos.Items {
+			allRepos = append(allRepos, &repos.Items[i])
+		}
 
 		if repos.Size < repos.Pagelen {
 			break
@@ -105,3 +121,35 @@ func (c *BitbucketClient) getRepos(cfg config.Config) ([]*bb.Repository, error)
 
 	return allRepos, nil
 }
+
+func toRepository(workspace string, repo *bb.Repository) client.Repository {
+	visibility := "public"
+	if repo.Is_private {
+		visibility = "private"
+	}
+
+	r := client.Repository{
+		ID:         repo.Uuid,
+		Owner:      workspace,
+		Name:       repo.Name,
+		HasWiki:    repo.Has_wiki,
+		Fork:       repo.Parent != nil,
+		Visibility: visibility,
+		Language:   repo.Language,
+	}
+
+	if repo.Parent != nil {
+		r.ForkOf = repo.Parent.Full_name
+		// Repositories are named by their name rather than their slug, the
+		// parent is named the same way so fork networks match up
+		if workspace, _, ok := strings.Cut(repo.Parent.Full_name, "/"); ok && repo.Parent.Name != "" {
+			r.ForkOf = workspace + "/" + repo.Parent.Name
+		}
+	}
+
+	if repo.UpdatedOnTime != nil {
+		r.PushedAt = *repo.UpdatedOnTime
+	}
+
+	return r
+}
@@ -0,0 +1,211 @@
+package bitbucketserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/token"
+)
+
+// BitbucketServerClient talks to the REST API of Bitbucket Server and Data
+// Center. Server.Domain may include a context path, e.g. example.com/bitbucket.
+type BitbucketServerClient struct {
+	tokenManager *token.Manager
+	serverConfig config.Server
+	httpClient   *http.Client
+}
+
+type page[T any] struct {
+	Values        []T  `json:"values"`
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+type project struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	Type string `json:"type"` // NORMAL or PERSONAL
+}
+
+type link struct {
+	Href string `json:"href"`
+	Name string `json:"name"`
+}
+
+type repository struct {
+	ID       int         `json:"id"`
+	Slug     string      `json:"slug"`
+	Name     string      `json:"name"`
+	Public   bool        `json:"public"`
+	Archived bool        `json:"archived"`
+	Project  project     `json:"project"`
+	Origin   *repository `json:"origin"`
+	Links    struct {
+		Clone []link `json:"clone"`
+	} `json:"links"`
+}
+
+func NewBitbucketServerClient(serverConfig config.Server, tokens []string) *BitbucketServerClient {
+	return &BitbucketServerClient{
+		tokenManager: token.NewManager(tokens),
+		serverConfig: serverConfig,
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (c *BitbucketServerClient) GetTokenManager() *token.Manager {
+	return c.tokenManager
+}
+
+// ListRepositories returns the repositories without wikis or issues, since
+// Bitbucket Server has no wikis and tracks issues in Jira
+func (c *BitbucketServerClient) ListRepositories(ctx context.Context, cfg config.Config) ([]client.Repository, error) {
+	repos, err := c.listRepos(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	allRepos := make([]client.Repository, 0, len(repos))
+	for _, repo := range repos {
+		allRepos = append(allRepos, c.toRepository(repo))
+	}
+
+	return allRepos, nil
+}
+
+// listRepos returns the repositories of every project visible to the token,
+// or only of the project keys listed in orgs, followed by the personal
+// repositories of the configured username and users, before any filtering
+func (c *BitbucketServerClient) listRepos(ctx context.Context, cfg config.Config) ([]*repository, error) {
+	projectKeys := cfg.Orgs
+	if len(projectKeys) == 0 {
+		logger.Debug("Fetching list of projects ⏳")
+		projects, err := getPaged[project](ctx, c, "/rest/api/1.0/projects")
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range projects {
+			projectKeys = append(projectKeys, p.Key)
+		}
+	}
+
+	var allRepos []*repository
+	for _, key := range projectKeys {
+		logger.Debugf("Fetching list of repositories for project %s ⏳", key)
+		repos, err := getPaged[*repository](ctx, c, fmt.Sprintf("/rest/api/1.0/projects/%s/repos", url.PathEscape(key)))
+		if err != nil {
+			return nil, err
+		}
+		allRepos = append(allRepos, repos...)
+	}
+
+	users := append([]string{cfg.Username}, cfg.Users...)
+	for _, user := range users {
+		logger.Debugf("Fetching list of personal repositories for user %s ⏳", user)
+		repos, err := getPaged[*repository](ctx, c, fmt.Sprintf("/rest/api/1.0/users/%s/repos", url.PathEscape(user)))
+		if err != nil {
+			return nil, err
+		}
+		allRepos = append(allRepos, repos...)
+	}
+
+	return allRepos, nil
+}
+
+// getPaged walks every page of a paged API resource
+func getPaged[T any](ctx context.Context, c *BitbucketServerClient, path string) ([]T, error) {
+	var all []T
+	start := 0
+
+	for {
+		var result page[T]
+		if err := c.get(ctx, path, url.Values{"start": {strconv.Itoa(start)}, "limit": {"100"}}, &result); err != nil {
+			return nil, err
+		}
+
+		all = append(all, result.Values...)
+		if result.IsLastPage {
+			break
+		}
+		start = result.NextPageStart
+	}
+
+	return all, nil
+}
+
+// get sends an API request authenticated with the next token, trying the
+// other tokens when it fails
+func (c *BitbucketServerClient) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	reqURL := fmt.Sprintf("%s://%s%s?%s", c.serverConfig.Protocol, strings.TrimSuffix(c.serverConfig.Domain, "/"), path, query.Encode())
+
+	return client.WithTokens(ctx, c.tokenManager, func(ctx context.Context) error {
+		return c.getOnce(ctx, path, reqURL, out)
+	})
+}
+
+func (c *BitbucketServerClient) getOnce(ctx context.Context, path, reqURL string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.tokenManager.GetNextToken())
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	if err := client.CheckResponse(resp, path); err != nil {
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// cloneURL returns the HTTP clone link of the repository, falling back to the
+// standard /scm/<project>/<repo>.git layout when the API does not report one
+func (c *BitbucketServerClient) cloneURL(repo *repository) string {
+	for _, l := range repo.Links.Clone {
+		if l.Name == "http" || l.Name == "https" {
+			return l.Href
+		}
+	}
+
+	return fmt.Sprintf("%s://%s/scm/%s/%s.git", c.serverConfig.Protocol, strings.TrimSuffix(c.serverConfig.Domain, "/"), strings.ToLower(repo.Project.Key), repo.Slug)
+}
+
+func (c *BitbucketServerClient) toRepository(repo *repository) client.Repository {
+	visibility := "private"
+	if repo.Public {
+		visibility = "public"
+	}
+
+	r := client.Repository{
+		ID:         strconv.Itoa(repo.ID),
+		Owner:      repo.Project.Key,
+		Name:       repo.Slug,
+		CloneURL:   c.cloneURL(repo),
+		Fork:       repo.Origin != nil,
+		Archived:   repo.Archived,
+		Visibility: visibility,
+	}
+	if repo.Origin != nil {
+		r.ForkOf = fmt.Sprintf("%s/%s", repo.Origin.Project.Key, repo.Origin.Slug)
+	}
+	return r
+}
@@ -1,11 +1,87 @@
 package client
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/issues"
 	"github.com/AkashRajpurohit/git-sync/pkg/token"
 )
 
+// Client discovers the repositories of a platform. Filtering, cloning, wikis,
+// issues and the summary are handled once for every platform by pkg/sync.
 type Client interface {
-	Sync(config config.Config) error
+	// ListRepositories returns every repository of the configured sources
+	// before any filtering. It gives up once ctx is cancelled.
+	ListRepositories(ctx context.Context, config config.Config) ([]Repository, error)
 	GetTokenManager() *token.Manager
 }
+
+// IssueFetcher is implemented by clients that can export issues. Only
+// repositories with HasIssues set are asked for them.
+type IssueFetcher interface {
+	// FetchIssues returns the issues of the repository, only those updated
+	// since the given time when incremental is set
+	FetchIssues(ctx context.Context, repo Repository, since time.Time, incremental bool) ([]issues.Issue, error)
+}
+
+// ForkResolver is implemented by clients whose listings leave out which
+// repository a fork was forked from. Only included forks without ForkOf are
+// asked, and only when forks share their objects.
+type ForkResolver interface {
+	// ForkOf returns the owner/name of the repository the fork was forked
+	// from
+	ForkOf(ctx context.Context, repo Repository) (string, error)
+}
+
+// OwnerKind tells whether a repository belongs to a user or to an organization
+// or group
+type OwnerKind int
+
+const (
+	OwnerUnknown OwnerKind = iota // the platform does not tell
+	OwnerUser
+	OwnerOrg
+)
+
+// Repository is a repository as discovered on a platform, normalized so it
+// can be filtered and backed up without knowing where it came from.
+type Repository struct {
+	ID         string // Platform specific identifier
+	Owner      string
+	OwnerKind  OwnerKind
+	Name       string
+	CloneURL   string // Empty when it is <protocol>://<domain>/<owner>/<name>.git
+	WikiURL    string // Empty when the wiki follows the platform's default layout
+	HasWiki    bool
+	HasIssues  bool
+	Fork       bool
+	ForkOf     string // owner/name of the repository it was forked from, when the platform lists it
+	Archived   bool
+	Visibility string // public, private or internal
+	Topics     []string
+	Language   string
+	PushedAt   time.Time
+	SizeKB     int64
+
+	// Namespace is a directory inside the backup directory the repository is
+	// stored in, such as _starred. Namespaced repositories are skipped when
+	// they are also discovered outside of a namespace.
+	Namespace string
+
+	// Raw repositories come from raw_git_urls. They are cloned from CloneURL
+	// as is and are never filtered.
+	Raw bool
+
+	// Container has no code of its own, only a wiki or issues, like an Azure
+	// DevOps project. It is backed up when a repository stored beneath it,
+	// i.e. owned by <owner>/<name>, is included, unless exclude_orgs or
+	// exclude_repos match the container itself.
+	Container bool
+}
+
+func (r Repository) FullName() string {
+	return fmt.Sprintf("%s/%s", r.Owner, r.Name)
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusError is an API request that failed with an error status. It tells
+// whether repeating the request could succeed and how long the platform asked
+// to wait before doing so.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // 0 when the response did not ask to wait
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent reports whether the request failed for a reason repeating it
+// cannot fix, such as a missing repository or rejected credentials. Rate
+// limited requests are answered with 403 by some platforms, so a 403 that
+// asks to wait is not permanent.
+func (e *StatusError) Permanent() bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusGone, http.StatusUnprocessableEntity:
+		return true
+	case http.StatusForbidden:
+		return e.RetryAfter == 0
+	}
+	return false
+}
+
+// NewStatusError returns a StatusError for resp that wraps err, reading the
+// Retry-After header of the response
+func NewStatusError(resp *http.Response, err error) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		Err:        err,
+	}
+}
+
+// CheckResponse returns a StatusError for responses with an error status and
+// nil for successful ones. path names the request in the error message.
+func CheckResponse(resp *http.Response, path string) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+	return NewStatusError(resp, fmt.Errorf("request to %s failed with status %d", path, resp.StatusCode))
+}
+
+// ParseRetryAfter parses a Retry-After header given in seconds or as an HTTP
+// date relative to now. It returns 0 for empty, invalid or past values.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,25 @@
+package client
+
+import "context"
+
+// Requester runs a single API request of a client, such as one page of a
+// listing. It may bound how long the request takes and repeat it when it
+// fails.
+type Requester func(ctx context.Context, request func(ctx context.Context) error) error
+
+type requesterKey struct{}
+
+// WithRequester returns a copy of ctx that runs the API requests of clients
+// with requester
+func WithRequester(ctx context.Context, requester Requester) context.Context {
+	return context.WithValue(ctx, requesterKey{}, requester)
+}
+
+// Request runs an API request with the Requester of ctx, or as is when it has
+// none
+func Request(ctx context.Context, request func(ctx context.Context) error) error {
+	if requester, ok := ctx.Value(requesterKey{}).(Requester); ok {
+		return requester(ctx, request)
+	}
+	return request(ctx)
+}
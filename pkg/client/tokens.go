@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/token"
+)
+
+// WithTokens sends an API request, which authenticates with the next token of
+// tokens, once for each token until one succeeds. Only when every token was
+// tried and the platform asked to wait before the next request is the request
+// sent once more, after the shortest wait asked for. The attempts run as a
+// single request of the Requester of ctx, which gets the error of the last one.
+func WithTokens(ctx context.Context, tokens *token.Manager, request func(ctx context.Context) error) error {
+	attempts := max(len(tokens.GetAllTokens()), 1)
+
+	return Request(ctx, func(ctx context.Context) error {
+		var err error
+		var wait time.Duration
+		for attempt := 1; attempt <= attempts; attempt++ {
+			if err = request(ctx); err == nil || ctx.Err() != nil {
+				return err
+			}
+
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 && (wait == 0 || statusErr.RetryAfter < wait) {
+				wait = statusErr.RetryAfter
+			}
+			if attempt < attempts {
+				logger.Debugf("Error with current token, trying next token: %v", err)
+			}
+		}
+		if wait == 0 {
+			return err
+		}
+
+		logger.Debugf("Every token failed, trying again in %s: %v", wait.Round(time.Second), err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		return request(ctx)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/AkashRajpurohit/git-sync/pkg/logger"
@@ -16,8 +17,88 @@ type Server struct {
 }
 
 type RetryConfig struct {
-	Count int `mapstructure:"count"`
-	Delay int `mapstructure:"delay"` // in seconds
+	Count    int    `mapstructure:"count"`
+	Delay    int    `mapstructure:"delay"`     // in seconds
+	Backoff  string `mapstructure:"backoff"`   // fixed or exponential, fixed when empty
+	MaxDelay int    `mapstructure:"max_delay"` // in seconds, 0 for no limit
+	Jitter   bool   `mapstructure:"jitter"`    // randomize delays so retries of parallel operations spread out
+}
+
+// TimeoutConfig limits how long a single attempt of an operation may take, in
+// seconds. 0 disables a limit. Attempts that run out of time are retried.
+type TimeoutConfig struct {
+	Clone         int `mapstructure:"clone"`
+	Fetch         int `mapstructure:"fetch"`
+	Wiki          int `mapstructure:"wiki"`
+	API           int `mapstructure:"api"`             // Listing repositories, and fetching the issues of a repository
+	LowSpeedLimit int `mapstructure:"low_speed_limit"` // in bytes per second, http(s) transfers slower than this for low_speed_time are aborted
+	LowSpeedTime  int `mapstructure:"low_speed_time"`
+}
+
+// Limits restrict how much of the network and the platforms a sync uses. 0
+// means no limit for bandwidth and per_host, and concurrency for the others,
+// which cannot be set higher than concurrency either.
+type Limits struct {
+	Bandwidth      int `mapstructure:"bandwidth"`       // in KiB per second, shared by all git transfers over http(s)
+	GitConcurrency int `mapstructure:"git_concurrency"` // git clones and fetches running at once
+	APIConcurrency int `mapstructure:"api_concurrency"` // API requests, such as issue fetches, running at once
+	PerHost        int `mapstructure:"per_host"`        // git clones and fetches running at once against a single host
+}
+
+// LimitsConfig holds the default limits and the schedules that replace them at
+// certain times of day
+type LimitsConfig struct {
+	Limits    `mapstructure:",squash"`
+	Schedules []LimitSchedule `mapstructure:"schedules"`
+}
+
+// LimitSchedule replaces the default limits with its non-zero ones between
+// start and end, on the given days or every day when there are none
+type LimitSchedule struct {
+	Days   []string `mapstructure:"days"`  // mon, tue, wed, thu, fri, sat or sun
+	Start  string   `mapstructure:"start"` // HH:MM in local time
+	End    string   `mapstructure:"end"`   // HH:MM, before start for schedules spanning midnight
+	Limits `mapstructure:",squash"`
+}
+
+// QueueConfig decides the order repositories are synced in and whether an
+// interrupted sync resumes where it stopped
+type QueueConfig struct {
+	Order          []string `mapstructure:"order"`           // priority, failed and pushed, applied in the given order. API order when empty.
+	PriorityRepos  []string `mapstructure:"priority_repos"`  // name or owner/name patterns the priority order puts first
+	PriorityTopics []string `mapstructure:"priority_topics"` // topic patterns the priority order puts first
+	Resume         bool     `mapstructure:"resume"`          // skip repositories an interrupted sync already completed, off by default
+}
+
+// MaintenanceConfig schedules the upkeep of the repositories in the backup
+// directory, which would otherwise collect packfiles and loose objects
+// forever
+type MaintenanceConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Cron        string `mapstructure:"cron"`         // own schedule while syncing with cron, after every sync when empty
+	Concurrency int    `mapstructure:"concurrency"`  // repositories maintained at once
+	PruneExpire string `mapstructure:"prune_expire"` // unreachable objects younger than this are kept, like 2.weeks.ago, or never
+}
+
+// CloneOptions tunes the partial and shallow-since clone types
+type CloneOptions struct {
+	BlobLimit    string `mapstructure:"blob_limit"`    // partial clones leave out blobs larger than this, like 512k or 1m
+	ShallowSince string `mapstructure:"shallow_since"` // shallow-since clones keep the history after this YYYY-MM-DD date or "<n> <unit>s ago"
+}
+
+// Override replaces settings for the repositories matching Repos. The first
+// matching override wins and settings it leaves empty keep their global value.
+type Override struct {
+	Repos         []string      `mapstructure:"repos"` // name or owner/name patterns, like include_repos
+	CloneType     string        `mapstructure:"clone_type"`
+	CloneOptions  CloneOptions  `mapstructure:"clone_options"`
+	IncludeWiki   *bool         `mapstructure:"include_wiki"`
+	IncludeIssues *bool         `mapstructure:"include_issues"`
+	IncludeLFS    *bool         `mapstructure:"include_lfs"`
+	Checkout      *bool         `mapstructure:"checkout"`
+	Timeouts      TimeoutConfig `mapstructure:"timeouts"`  // non-zero timeouts replace the global ones
+	Priority      bool          `mapstructure:"priority"`  // sync before the other repositories when queue.order contains priority
+	Directory     string        `mapstructure:"directory"` // sub-directory of the backup directory the repositories are stored in
 }
 
 type NotificationConfig struct {
@@ -42,32 +123,81 @@ type GotifyConfig struct {
 	Priority int    `mapstructure:"priority"`
 }
 
+// FilterConfig holds repository attribute filters applied on top of the
+// include/exclude lists. Evaluation order is documented in pkg/filter.
+type FilterConfig struct {
+	ExcludeArchived bool     `mapstructure:"exclude_archived"`
+	Visibility      []string `mapstructure:"visibility"`     // public, private and/or internal
+	IncludeTopics   []string `mapstructure:"include_topics"` // glob or regex: patterns
+	ExcludeTopics   []string `mapstructure:"exclude_topics"` // glob or regex: patterns
+	Languages       []string `mapstructure:"languages"`
+	MaxInactiveDays int      `mapstructure:"max_inactive_days"` // 0 disables the check
+	MinSizeMB       int      `mapstructure:"min_size_mb"`       // 0 disables the check
+	MaxSizeMB       int      `mapstructure:"max_size_mb"`       // 0 disables the check
+}
+
+// GitLabConfig holds options that only apply to the gitlab platform
+type GitLabConfig struct {
+	MinAccessLevel   string   `mapstructure:"min_access_level"`  // guest, reporter, developer, maintainer or owner. Lists projects by membership instead of ownership
+	Groups           []string `mapstructure:"groups"`            // Group IDs or full paths to back up
+	IncludeSubgroups bool     `mapstructure:"include_subgroups"` // Also back up projects of subgroups of groups
+	IncludeShared    bool     `mapstructure:"include_shared"`    // Also back up projects shared with groups and orgs
+}
+
+// ExecConfig holds the external program used by the exec platform
+type ExecConfig struct {
+	Command string   `mapstructure:"command"` // Program listing repositories, see pkg/plugin for the protocol
+	Args    []string `mapstructure:"args"`    // Arguments passed before the subcommand
+}
+
 type TelemetryConfig struct {
 	Enabled bool `mapstructure:"enabled"`
 }
 
 type Config struct {
-	Username      string             `mapstructure:"username"`
-	Token         string             `mapstructure:"token"`  // Deprecated: Use Tokens instead
-	Tokens        []string           `mapstructure:"tokens"` // New field for multiple tokens
-	Platform      string             `mapstructure:"platform"`
-	Server        Server             `mapstructure:"server"`
-	IncludeRepos  []string           `mapstructure:"include_repos"`
-	ExcludeRepos  []string           `mapstructure:"exclude_repos"`
-	IncludeOrgs   []string           `mapstructure:"include_orgs"`
-	ExcludeOrgs   []string           `mapstructure:"exclude_orgs"`
-	IncludeForks  bool               `mapstructure:"include_forks"`
-	IncludeWiki   bool               `mapstructure:"include_wiki"`
-	IncludeIssues bool               `mapstructure:"include_issues"`
-	BackupDir     string             `mapstructure:"backup_dir"`
-	Workspace     string             `mapstructure:"workspace"`
-	Cron          string             `mapstructure:"cron"`
-	CloneType     string             `mapstructure:"clone_type"`
-	RawGitURLs    []string           `mapstructure:"raw_git_urls"`
-	Concurrency   int                `mapstructure:"concurrency"`
-	Retry         RetryConfig        `mapstructure:"retry"`
-	Notification  NotificationConfig `mapstructure:"notification"`
-	Telemetry     TelemetryConfig    `mapstructure:"telemetry"`
+	Username         string             `mapstructure:"username"`
+	Token            string             `mapstructure:"token"`  // Deprecated: Use Tokens instead
+	Tokens           []string           `mapstructure:"tokens"` // New field for multiple tokens
+	Platform         string             `mapstructure:"platform"`
+	Server           Server             `mapstructure:"server"`
+	IncludeRepos     []string           `mapstructure:"include_repos"`
+	ExcludeRepos     []string           `mapstructure:"exclude_repos"`
+	IncludeOrgs      []string           `mapstructure:"include_orgs"`
+	ExcludeOrgs      []string           `mapstructure:"exclude_orgs"`
+	IncludeForks     bool               `mapstructure:"include_forks"`
+	IncludeWiki      bool               `mapstructure:"include_wiki"`
+	IncludeIssues    bool               `mapstructure:"include_issues"`
+	IncludeLFS       bool               `mapstructure:"include_lfs"` // Also fetch the Git LFS objects of repositories, needs git-lfs
+	Filters          FilterConfig       `mapstructure:"filters"`
+	Users            []string           `mapstructure:"users"`                // Additional users whose repositories are backed up
+	Orgs             []string           `mapstructure:"orgs"`                 // Additional orgs, groups or workspaces whose repositories are backed up
+	IncludeStarred   bool               `mapstructure:"include_starred"`      // GitHub only, backed up into BackupDir/_starred
+	IncludeWatched   bool               `mapstructure:"include_watched"`      // GitHub only, backed up into BackupDir/_watched
+	StarredMaxSizeMB int                `mapstructure:"starred_max_size_mb"`  // Size cap for starred and watched repos, 0 disables it
+	MaxRepoSize      int                `mapstructure:"max_repo_size"`        // in MB as the platform reports it, larger repos are not cloned as is, 0 disables it. Bitbucket and Sourcehut report no sizes.
+	OversizeAction   string             `mapstructure:"max_repo_size_action"` // skip or shallow clone repos above max_repo_size, skip when empty
+	MinFreeSpace     int                `mapstructure:"min_free_space"`       // in MB, no new repos are cloned while the backup disk has less free, 0 disables it
+	GitLab           GitLabConfig       `mapstructure:"gitlab"`
+	Exec             ExecConfig         `mapstructure:"exec"`
+	BackupDir        string             `mapstructure:"backup_dir"`
+	Workspace        string             `mapstructure:"workspace"`
+	Cron             string             `mapstructure:"cron"`
+	CronOverlap      string             `mapstructure:"cron_overlap"` // skip or queue a run that is due while the previous one is still running, skip when empty
+	CloneType        string             `mapstructure:"clone_type"`
+	CloneOptions     CloneOptions       `mapstructure:"clone_options"`
+	Checkout         bool               `mapstructure:"checkout"`          // Also keep a checkout of the default branch next to clones without a worktree
+	DeduplicateForks bool               `mapstructure:"deduplicate_forks"` // Let forks share their objects through an object pool per fork network
+	Overrides        []Override         `mapstructure:"overrides"`
+	RawGitURLs       []string           `mapstructure:"raw_git_urls"`
+	Concurrency      int                `mapstructure:"concurrency"`
+	Retry            RetryConfig        `mapstructure:"retry"`
+	ShutdownTimeout  int                `mapstructure:"shutdown_timeout"` // in seconds, how long running operations may finish after SIGINT or SIGTERM
+	Timeouts         TimeoutConfig      `mapstructure:"timeouts"`
+	Limits           LimitsConfig       `mapstructure:"limits"`
+	Queue            QueueConfig        `mapstructure:"queue"`
+	Maintenance      MaintenanceConfig  `mapstructure:"maintenance"`
+	Notification     NotificationConfig `mapstructure:"notification"`
+	Telemetry        TelemetryConfig    `mapstructure:"telemetry"`
 }
 
 func expandPath(path string) string {
@@ -136,6 +266,18 @@ func LoadConfig(cfgFile string) (Config, error) {
 	return config, nil
 }
 
+// ConfiguredKeys returns the keys set in the config file LoadConfig read, for
+// SetSensibleDefaults to tell missing options from ones set to zero
+func ConfiguredKeys() map[string]bool {
+	configured := make(map[string]bool)
+	for _, key := range viper.AllKeys() {
+		if viper.InConfig(key) {
+			configured[key] = true
+		}
+	}
+	return configured
+}
+
 func SaveConfig(config Config, cfgFile string) error {
 	configFile := GetConfigFile(cfgFile)
 
@@ -151,15 +293,76 @@ func SaveConfig(config Config, cfgFile string) error {
 	viper.Set("include_forks", config.IncludeForks)
 	viper.Set("include_wiki", config.IncludeWiki)
 	viper.Set("include_issues", config.IncludeIssues)
+	viper.Set("include_lfs", config.IncludeLFS)
+	viper.Set("filters.exclude_archived", config.Filters.ExcludeArchived)
+	viper.Set("filters.visibility", config.Filters.Visibility)
+	viper.Set("filters.include_topics", config.Filters.IncludeTopics)
+	viper.Set("filters.exclude_topics", config.Filters.ExcludeTopics)
+	viper.Set("filters.languages", config.Filters.Languages)
+	viper.Set("filters.max_inactive_days", config.Filters.MaxInactiveDays)
+	viper.Set("filters.min_size_mb", config.Filters.MinSizeMB)
+	viper.Set("filters.max_size_mb", config.Filters.MaxSizeMB)
+	viper.Set("users", config.Users)
+	viper.Set("orgs", config.Orgs)
+	viper.Set("include_starred", config.IncludeStarred)
+	viper.Set("include_watched", config.IncludeWatched)
+	viper.Set("starred_max_size_mb", config.StarredMaxSizeMB)
+	viper.Set("max_repo_size", config.MaxRepoSize)
+	viper.Set("max_repo_size_action", config.OversizeAction)
+	viper.Set("min_free_space", config.MinFreeSpace)
+	viper.Set("gitlab.min_access_level", config.GitLab.MinAccessLevel)
+	viper.Set("gitlab.groups", config.GitLab.Groups)
+	viper.Set("gitlab.include_subgroups", config.GitLab.IncludeSubgroups)
+	viper.Set("gitlab.include_shared", config.GitLab.IncludeShared)
+	viper.Set("exec.command", config.Exec.Command)
+	viper.Set("exec.args", config.Exec.Args)
 	viper.Set("backup_dir", config.BackupDir)
 	viper.Set("platform", config.Platform)
 	viper.Set("server", config.Server)
 	viper.Set("workspace", config.Workspace)
 	viper.Set("cron", config.Cron)
+	viper.Set("cron_overlap", config.CronOverlap)
 	viper.Set("clone_type", config.CloneType)
+	viper.Set("clone_options.blob_limit", config.CloneOptions.BlobLimit)
+	viper.Set("clone_options.shallow_since", config.CloneOptions.ShallowSince)
+	viper.Set("checkout", config.Checkout)
+	viper.Set("deduplicate_forks", config.DeduplicateForks)
+	overrides := make([]map[string]any, 0, len(config.Overrides))
+	for _, override := range config.Overrides {
+		overrides = append(overrides, override.settings())
+	}
+	viper.Set("overrides", overrides)
 	viper.Set("raw_git_urls", config.RawGitURLs)
 	viper.Set("concurrency", config.Concurrency)
-	viper.Set("retry", config.Retry)
+	viper.Set("retry.count", config.Retry.Count)
+	viper.Set("retry.delay", config.Retry.Delay)
+	viper.Set("retry.backoff", config.Retry.Backoff)
+	viper.Set("retry.max_delay", config.Retry.MaxDelay)
+	viper.Set("retry.jitter", config.Retry.Jitter)
+	viper.Set("shutdown_timeout", config.ShutdownTimeout)
+	viper.Set("timeouts.clone", config.Timeouts.Clone)
+	viper.Set("timeouts.fetch", config.Timeouts.Fetch)
+	viper.Set("timeouts.wiki", config.Timeouts.Wiki)
+	viper.Set("timeouts.api", config.Timeouts.API)
+	viper.Set("timeouts.low_speed_limit", config.Timeouts.LowSpeedLimit)
+	viper.Set("timeouts.low_speed_time", config.Timeouts.LowSpeedTime)
+	viper.Set("limits.bandwidth", config.Limits.Bandwidth)
+	viper.Set("limits.git_concurrency", config.Limits.GitConcurrency)
+	viper.Set("limits.api_concurrency", config.Limits.APIConcurrency)
+	viper.Set("limits.per_host", config.Limits.PerHost)
+	schedules := make([]map[string]any, 0, len(config.Limits.Schedules))
+	for _, schedule := range config.Limits.Schedules {
+		schedules = append(schedules, schedule.settings())
+	}
+	viper.Set("limits.schedules", schedules)
+	viper.Set("queue.order", config.Queue.Order)
+	viper.Set("queue.priority_repos", config.Queue.PriorityRepos)
+	viper.Set("queue.priority_topics", config.Queue.PriorityTopics)
+	viper.Set("queue.resume", config.Queue.Resume)
+	viper.Set("maintenance.enabled", config.Maintenance.Enabled)
+	viper.Set("maintenance.cron", config.Maintenance.Cron)
+	viper.Set("maintenance.concurrency", config.Maintenance.Concurrency)
+	viper.Set("maintenance.prune_expire", config.Maintenance.PruneExpire)
 	viper.Set("notification", config.Notification)
 	viper.Set("telemetry", config.Telemetry)
 
@@ -182,15 +385,55 @@ func GetInitialConfig() Config {
 		IncludeForks:  false,
 		IncludeWiki:   true,
 		IncludeIssues: false,
-		Workspace:     "",
-		Cron:          "",
-		BackupDir:     GetBackupDir(""),
-		CloneType:     "bare",
-		RawGitURLs:    []string{},
-		Concurrency:   5,
-		Retry: RetryConfig{
-			Count: 3,
-			Delay: 5,
+		IncludeLFS:    false,
+		Filters: FilterConfig{
+			Visibility:    []string{},
+			IncludeTopics: []string{},
+			ExcludeTopics: []string{},
+			Languages:     []string{},
+		},
+		Users:            []string{},
+		Orgs:             []string{},
+		IncludeStarred:   false,
+		IncludeWatched:   false,
+		StarredMaxSizeMB: 0,
+		MaxRepoSize:      0,
+		OversizeAction:   "skip",
+		MinFreeSpace:     0,
+		GitLab: GitLabConfig{
+			Groups:           []string{},
+			IncludeSubgroups: true,
+		},
+		Exec: ExecConfig{
+			Args: []string{},
+		},
+		Workspace:        "",
+		Cron:             "",
+		CronOverlap:      "skip",
+		BackupDir:        GetBackupDir(""),
+		CloneType:        "bare",
+		Checkout:         false,
+		DeduplicateForks: false,
+		Overrides:        []Override{},
+		RawGitURLs:       []string{},
+		Concurrency:      5,
+		Retry:            defaultRetry,
+		ShutdownTimeout:  60,
+		Timeouts:         defaultTimeouts,
+		Limits: LimitsConfig{
+			Schedules: []LimitSchedule{},
+		},
+		Queue: QueueConfig{
+			Order:          slices.Clone(defaultQueueOrder),
+			PriorityRepos:  []string{},
+			PriorityTopics: []string{},
+			Resume:         false,
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:     false,
+			Cron:        "",
+			Concurrency: 2,
+			PruneExpire: "2.weeks.ago",
 		},
 		Telemetry: TelemetryConfig{
 			Enabled: true,
@@ -1,8 +1,33 @@
 package config
 
-import "github.com/AkashRajpurohit/git-sync/pkg/logger"
+import (
+	"slices"
 
-func SetSensibleDefaults(cfg *Config) {
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+var (
+	// Backoff, max_delay and jitter are opt-in, retries wait a fixed delay
+	// unless they are set
+	defaultRetry = RetryConfig{
+		Count: 3,
+		Delay: 5,
+	}
+	// Clones and fetches of large repositories can take any time, so they
+	// are only aborted when they stall, see low_speed_limit
+	defaultTimeouts = TimeoutConfig{
+		Wiki:          600,
+		API:           600,
+		LowSpeedLimit: 1000,
+		LowSpeedTime:  300,
+	}
+	defaultQueueOrder = []string{"priority", "failed", "pushed"}
+)
+
+// SetSensibleDefaults fills in what cfg leaves out. configured holds the keys
+// that were set explicitly, options missing from it get their defaults even
+// when their zero value is meaningful.
+func SetSensibleDefaults(cfg *Config, configured map[string]bool) {
 	if cfg.Platform != "" && (cfg.Server.Domain == "" || cfg.Server.Protocol == "") {
 		if cfg.Platform == "github" {
 			cfg.Server.Domain = "github.com"
@@ -43,6 +68,38 @@ func SetSensibleDefaults(cfg *Config) {
 		cfg.CloneType = "bare"
 	}
 
+	// shutdown_timeout is optional, so it silently defaults to a minute
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = 60
+	}
+
+	// Config files written before the timeout and queue options were
+	// added do not have them. Their zero values disable them, so they are
+	// only defaulted when missing from the file.
+	optional := []struct {
+		key   string
+		apply func()
+	}{
+		{"timeouts.wiki", func() { cfg.Timeouts.Wiki = defaultTimeouts.Wiki }},
+		{"timeouts.api", func() { cfg.Timeouts.API = defaultTimeouts.API }},
+		{"timeouts.low_speed_limit", func() { cfg.Timeouts.LowSpeedLimit = defaultTimeouts.LowSpeedLimit }},
+		{"timeouts.low_speed_time", func() { cfg.Timeouts.LowSpeedTime = defaultTimeouts.LowSpeedTime }},
+		{"queue.order", func() { cfg.Queue.Order = slices.Clone(defaultQueueOrder) }},
+	}
+	for _, option := range optional {
+		if !configured[option.key] {
+			option.apply()
+		}
+	}
+
+	// So are the maintenance settings
+	if cfg.Maintenance.Concurrency == 0 {
+		cfg.Maintenance.Concurrency = 2
+	}
+	if cfg.Maintenance.PruneExpire == "" {
+		cfg.Maintenance.PruneExpire = "2.weeks.ago"
+	}
+
 	// If both are set, merge them with single token being first
 	if cfg.Token != "" && len(cfg.Tokens) > 0 {
 		logger.Warn("Both 'token' and 'tokens' fields are set. 'token' field is deprecated and will be merged with 'tokens'.")
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/AkashRajpurohit/git-sync/pkg/logger"
@@ -161,7 +162,7 @@ func TestSetSensibleDefaults(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			logger.InitLogger("fatal")
 			cfg := tt.cfg
-			SetSensibleDefaults(&cfg)
+			SetSensibleDefaults(&cfg, nil)
 
 			if cfg.Server.Domain != tt.expected.Server.Domain {
 				t.Errorf("Server Domain = %v, want %v", cfg.Server.Domain, tt.expected.Server.Domain)
@@ -189,3 +190,61 @@ func TestSetSensibleDefaults(t *testing.T) {
 		})
 	}
 }
+
+func TestSetSensibleDefaultsOptionalSettings(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured []string
+		cfg        Config
+		expected   Config
+	}{
+		{
+			name: "Missing options get their defaults",
+			expected: Config{
+				Timeouts: defaultTimeouts,
+				Queue:    QueueConfig{Order: defaultQueueOrder},
+			},
+		},
+		{
+			name: "Options set to their zero value are kept",
+			configured: []string{
+				"timeouts.wiki", "timeouts.api", "timeouts.low_speed_limit", "timeouts.low_speed_time",
+				"queue.order",
+			},
+			cfg:      Config{Queue: QueueConfig{Order: []string{}}},
+			expected: Config{Queue: QueueConfig{Order: []string{}}},
+		},
+		{
+			name:       "Options set are kept",
+			configured: []string{"timeouts.api", "queue.resume"},
+			cfg:        Config{Timeouts: TimeoutConfig{API: 30}, Queue: QueueConfig{Resume: true}},
+			expected: Config{
+				Timeouts: TimeoutConfig{Wiki: 600, API: 30, LowSpeedLimit: 1000, LowSpeedTime: 300},
+				Queue:    QueueConfig{Order: defaultQueueOrder, Resume: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger.InitLogger("fatal")
+			configured := make(map[string]bool)
+			for _, key := range tt.configured {
+				configured[key] = true
+			}
+
+			cfg := tt.cfg
+			SetSensibleDefaults(&cfg, configured)
+
+			if cfg.Retry != (RetryConfig{}) {
+				t.Errorf("Retry = %+v, want it left as configured", cfg.Retry)
+			}
+			if cfg.Timeouts != tt.expected.Timeouts {
+				t.Errorf("Timeouts = %+v, want %+v", cfg.Timeouts, tt.expected.Timeouts)
+			}
+			if !reflect.DeepEqual(cfg.Queue, tt.expected.Queue) {
+				t.Errorf("Queue = %+v, want %+v", cfg.Queue, tt.expected.Queue)
+			}
+		})
+	}
+}
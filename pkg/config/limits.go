@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// At returns the limits in effect at t. The first schedule covering t
+// replaces the default limits it sets.
+func (l LimitsConfig) At(t time.Time) Limits {
+	limits := l.Limits
+	for _, schedule := range l.Schedules {
+		if !schedule.covers(t) {
+			continue
+		}
+		if schedule.Bandwidth > 0 {
+			limits.Bandwidth = schedule.Bandwidth
+		}
+		if schedule.GitConcurrency > 0 {
+			limits.GitConcurrency = schedule.GitConcurrency
+		}
+		if schedule.APIConcurrency > 0 {
+			limits.APIConcurrency = schedule.APIConcurrency
+		}
+		if schedule.PerHost > 0 {
+			limits.PerHost = schedule.PerHost
+		}
+		break
+	}
+	return limits
+}
+
+// covers reports whether t falls within the schedule. Schedules spanning
+// midnight belong to the day they start on.
+func (s LimitSchedule) covers(t time.Time) bool {
+	start, errStart := parseClock(s.Start)
+	end, errEnd := parseClock(s.End)
+	if errStart != nil || errEnd != nil {
+		return false
+	}
+
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	day := t.Weekday()
+	switch {
+	case start < end:
+		if now < start || now >= end {
+			return false
+		}
+	case now >= start:
+	case now < end:
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, name := range s.Days {
+		if weekdays[strings.ToLower(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses a HH:MM time of day into the time since midnight
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// settings returns the schedule keyed by its config key names, which viper
+// would otherwise write as the lowercased field names
+func (s LimitSchedule) settings() map[string]any {
+	return map[string]any{
+		"days":            s.Days,
+		"start":           s.Start,
+		"end":             s.End,
+		"bandwidth":       s.Bandwidth,
+		"git_concurrency": s.GitConcurrency,
+		"api_concurrency": s.APIConcurrency,
+		"per_host":        s.PerHost,
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimitsConfigAt(t *testing.T) {
+	limits := LimitsConfig{
+		Limits: Limits{GitConcurrency: 10, PerHost: 4},
+		Schedules: []LimitSchedule{
+			{
+				Days:   []string{"mon", "tue", "wed", "thu", "fri"},
+				Start:  "09:00",
+				End:    "18:00",
+				Limits: Limits{Bandwidth: 1024, GitConcurrency: 2},
+			},
+			{
+				Start:  "22:00",
+				End:    "06:00",
+				Limits: Limits{PerHost: 8},
+			},
+		},
+	}
+
+	// 2026-10-19 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want Limits
+	}{
+		{
+			name: "Weekday working hours",
+			t:    at(19, 10, 30),
+			want: Limits{Bandwidth: 1024, GitConcurrency: 2, PerHost: 4},
+		},
+		{
+			name: "Schedule start is inclusive",
+			t:    at(19, 9, 0),
+			want: Limits{Bandwidth: 1024, GitConcurrency: 2, PerHost: 4},
+		},
+		{
+			name: "Schedule end is exclusive",
+			t:    at(19, 18, 0),
+			want: Limits{GitConcurrency: 10, PerHost: 4},
+		},
+		{
+			name: "Weekend working hours",
+			t:    at(24, 10, 30),
+			want: Limits{GitConcurrency: 10, PerHost: 4},
+		},
+		{
+			name: "Overnight schedule before midnight",
+			t:    at(19, 23, 0),
+			want: Limits{GitConcurrency: 10, PerHost: 8},
+		},
+		{
+			name: "Overnight schedule after midnight",
+			t:    at(20, 5, 59),
+			want: Limits{GitConcurrency: 10, PerHost: 8},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := limits.At(tt.t); got != tt.want {
+				t.Errorf("At() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitScheduleDays(t *testing.T) {
+	// Starts on Friday night and runs into Saturday morning
+	schedule := LimitSchedule{Days: []string{"fri"}, Start: "20:00", End: "04:00"}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{name: "Friday night", t: time.Date(2026, 10, 23, 21, 0, 0, 0, time.Local), want: true},
+		{name: "Saturday morning", t: time.Date(2026, 10, 24, 3, 0, 0, 0, time.Local), want: true},
+		{name: "Friday morning", t: time.Date(2026, 10, 23, 3, 0, 0, 0, time.Local), want: false},
+		{name: "Saturday night", t: time.Date(2026, 10, 24, 21, 0, 0, 0, time.Local), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schedule.covers(tt.t); got != tt.want {
+				t.Errorf("covers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import "path/filepath"
+
+// Apply returns cfg with the settings the override sets
+func (o Override) Apply(cfg Config) Config {
+	if o.CloneType != "" {
+		cfg.CloneType = o.CloneType
+	}
+	if o.CloneOptions.BlobLimit != "" {
+		cfg.CloneOptions.BlobLimit = o.CloneOptions.BlobLimit
+	}
+	if o.CloneOptions.ShallowSince != "" {
+		cfg.CloneOptions.ShallowSince = o.CloneOptions.ShallowSince
+	}
+	if o.IncludeWiki != nil {
+		cfg.IncludeWiki = *o.IncludeWiki
+	}
+	if o.IncludeIssues != nil {
+		cfg.IncludeIssues = *o.IncludeIssues
+	}
+	if o.IncludeLFS != nil {
+		cfg.IncludeLFS = *o.IncludeLFS
+	}
+	if o.Checkout != nil {
+		cfg.Checkout = *o.Checkout
+	}
+	cfg.Timeouts = o.Timeouts.applyTo(cfg.Timeouts)
+	if o.Directory != "" {
+		cfg.BackupDir = filepath.Join(cfg.BackupDir, o.Directory)
+	}
+	return cfg
+}
+
+// applyTo returns timeouts with the non-zero ones of t replacing theirs
+func (t TimeoutConfig) applyTo(timeouts TimeoutConfig) TimeoutConfig {
+	for _, pair := range []struct{ from, to *int }{
+		{&t.Clone, &timeouts.Clone},
+		{&t.Fetch, &timeouts.Fetch},
+		{&t.Wiki, &timeouts.Wiki},
+		{&t.API, &timeouts.API},
+		{&t.LowSpeedLimit, &timeouts.LowSpeedLimit},
+		{&t.LowSpeedTime, &timeouts.LowSpeedTime},
+	} {
+		if *pair.from != 0 {
+			*pair.to = *pair.from
+		}
+	}
+	return timeouts
+}
+
+// WikiEnabled reports whether the wikis of any repository are backed up,
+// because of include_wiki or an override
+func (c Config) WikiEnabled() bool {
+	if c.IncludeWiki {
+		return true
+	}
+	for _, override := range c.Overrides {
+		if override.IncludeWiki != nil && *override.IncludeWiki {
+			return true
+		}
+	}
+	return false
+}
+
+// IssuesEnabled reports whether the issues of any repository are backed up,
+// because of include_issues or an override
+func (c Config) IssuesEnabled() bool {
+	if c.IncludeIssues {
+		return true
+	}
+	for _, override := range c.Overrides {
+		if override.IncludeIssues != nil && *override.IncludeIssues {
+			return true
+		}
+	}
+	return false
+}
+
+// settings returns the override keyed by its config key names, which viper
+// would otherwise write as the lowercased field names. Settings the override
+// leaves empty are left out so they keep following the global ones.
+func (o Override) settings() map[string]any {
+	settings := map[string]any{"repos": o.Repos}
+	cloneOptions := map[string]any{}
+	timeouts := map[string]any{}
+	set := func(settings map[string]any, key string, value any, isSet bool) {
+		if isSet {
+			settings[key] = value
+		}
+	}
+
+	set(settings, "clone_type", o.CloneType, o.CloneType != "")
+	set(cloneOptions, "blob_limit", o.CloneOptions.BlobLimit, o.CloneOptions.BlobLimit != "")
+	set(cloneOptions, "shallow_since", o.CloneOptions.ShallowSince, o.CloneOptions.ShallowSince != "")
+	set(settings, "clone_options", cloneOptions, len(cloneOptions) > 0)
+	set(settings, "include_wiki", o.IncludeWiki, o.IncludeWiki != nil)
+	set(settings, "include_issues", o.IncludeIssues, o.IncludeIssues != nil)
+	set(settings, "include_lfs", o.IncludeLFS, o.IncludeLFS != nil)
+	set(settings, "checkout", o.Checkout, o.Checkout != nil)
+	set(timeouts, "clone", o.Timeouts.Clone, o.Timeouts.Clone != 0)
+	set(timeouts, "fetch", o.Timeouts.Fetch, o.Timeouts.Fetch != 0)
+	set(timeouts, "wiki", o.Timeouts.Wiki, o.Timeouts.Wiki != 0)
+	set(timeouts, "api", o.Timeouts.API, o.Timeouts.API != 0)
+	set(timeouts, "low_speed_limit", o.Timeouts.LowSpeedLimit, o.Timeouts.LowSpeedLimit != 0)
+	set(timeouts, "low_speed_time", o.Timeouts.LowSpeedTime, o.Timeouts.LowSpeedTime != 0)
+	set(settings, "timeouts", timeouts, len(timeouts) > 0)
+	set(settings, "priority", o.Priority, o.Priority)
+	set(settings, "directory", o.Directory, o.Directory != "")
+	return settings
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOverrideApply(t *testing.T) {
+	enabled, disabled := true, false
+	cfg := Config{
+		BackupDir:     "backups",
+		CloneType:     "bare",
+		IncludeWiki:   true,
+		IncludeIssues: false,
+		Timeouts:      TimeoutConfig{Clone: 600, Fetch: 300},
+	}
+
+	tests := []struct {
+		name     string
+		override Override
+		want     Config
+	}{
+		{
+			name:     "Empty override keeps everything",
+			override: Override{Repos: []string{"*"}},
+			want:     cfg,
+		},
+		{
+			name: "Flags can be turned off and on",
+			override: Override{
+				Repos:         []string{"*"},
+				IncludeWiki:   &disabled,
+				IncludeIssues: &enabled,
+				IncludeLFS:    &enabled,
+				Checkout:      &enabled,
+			},
+			want: Config{
+				BackupDir:     "backups",
+				CloneType:     "bare",
+				Checkout:      true,
+				IncludeWiki:   false,
+				IncludeIssues: true,
+				IncludeLFS:    true,
+				Timeouts:      TimeoutConfig{Clone: 600, Fetch: 300},
+			},
+		},
+		{
+			name: "Non-zero timeouts replace the global ones",
+			override: Override{
+				Repos:    []string{"*"},
+				Timeouts: TimeoutConfig{Clone: 3600, API: 30},
+			},
+			want: Config{
+				BackupDir:   "backups",
+				CloneType:   "bare",
+				IncludeWiki: true,
+				Timeouts:    TimeoutConfig{Clone: 3600, Fetch: 300, API: 30},
+			},
+		},
+		{
+			name: "Clone type and directory",
+			override: Override{
+				Repos:        []string{"*"},
+				CloneType:    "partial",
+				CloneOptions: CloneOptions{BlobLimit: "1m"},
+				Directory:    "monorepos",
+			},
+			want: Config{
+				BackupDir:    filepath.Join("backups", "monorepos"),
+				CloneType:    "partial",
+				CloneOptions: CloneOptions{BlobLimit: "1m"},
+				IncludeWiki:  true,
+				Timeouts:     TimeoutConfig{Clone: 600, Fetch: 300},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.override.Apply(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnabledByOverrides(t *testing.T) {
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name       string
+		cfg        Config
+		wantWiki   bool
+		wantIssues bool
+	}{
+		{
+			name: "Disabled everywhere",
+			cfg:  Config{Overrides: []Override{{Repos: []string{"*"}, IncludeWiki: &disabled}}},
+		},
+		{
+			name:       "Enabled globally",
+			cfg:        Config{IncludeWiki: true, IncludeIssues: true},
+			wantWiki:   true,
+			wantIssues: true,
+		},
+		{
+			name:       "Enabled by an override",
+			cfg:        Config{Overrides: []Override{{Repos: []string{"docs"}, IncludeWiki: &enabled}, {Repos: []string{"app"}, IncludeIssues: &enabled}}},
+			wantWiki:   true,
+			wantIssues: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.WikiEnabled(); got != tt.wantWiki {
+				t.Errorf("WikiEnabled() = %v, want %v", got, tt.wantWiki)
+			}
+			if got := tt.cfg.IssuesEnabled(); got != tt.wantIssues {
+				t.Errorf("IssuesEnabled() = %v, want %v", got, tt.wantIssues)
+			}
+		})
+	}
+}
@@ -3,7 +3,10 @@ package config
 import (
 	"fmt"
 	"net/url"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/robfig/cron/v3"
 )
@@ -36,6 +39,24 @@ func validateGitURL(rawURL string) error {
 	return nil
 }
 
+func validateFilters(cfg Config) error {
+	for _, visibility := range cfg.Filters.Visibility {
+		if visibility != "public" && visibility != "private" && visibility != "internal" {
+			return fmt.Errorf("filters.visibility can only contain `public`, `private` or `internal`")
+		}
+	}
+
+	if cfg.Filters.MaxInactiveDays < 0 || cfg.Filters.MinSizeMB < 0 || cfg.Filters.MaxSizeMB < 0 {
+		return fmt.Errorf("filters.max_inactive_days, filters.min_size_mb and filters.max_size_mb cannot be negative")
+	}
+
+	if cfg.Filters.MaxSizeMB > 0 && cfg.Filters.MinSizeMB > cfg.Filters.MaxSizeMB {
+		return fmt.Errorf("filters.min_size_mb cannot be greater than filters.max_size_mb")
+	}
+
+	return nil
+}
+
 func ValidateConfig(cfg Config) error {
 	// Validate backup directory (required for all cases)
 	if cfg.BackupDir == "" {
@@ -43,8 +64,12 @@ func ValidateConfig(cfg Config) error {
 	}
 
 	// Validate clone type (required for all cases)
-	if cfg.CloneType != "bare" && cfg.CloneType != "full" && cfg.CloneType != "mirror" && cfg.CloneType != "shallow" {
-		return fmt.Errorf("clone_type can only be `bare`, `full`, `mirror` or `shallow`")
+	if err := validateClone("", cfg.CloneType, cfg.CloneOptions); err != nil {
+		return err
+	}
+
+	if err := validateOverrides(cfg); err != nil {
+		return err
 	}
 
 	// Validate concurrency
@@ -52,6 +77,26 @@ func ValidateConfig(cfg Config) error {
 		return fmt.Errorf("concurrency must be between 1 and 20")
 	}
 
+	if err := validateRetry(cfg.Retry); err != nil {
+		return err
+	}
+
+	if cfg.ShutdownTimeout < 0 {
+		return fmt.Errorf("shutdown_timeout cannot be negative")
+	}
+
+	if err := validateTimeouts(cfg.Timeouts); err != nil {
+		return err
+	}
+
+	if err := validateLimits(cfg.Limits, cfg.Concurrency); err != nil {
+		return err
+	}
+
+	if err := validateQueue(cfg.Queue); err != nil {
+		return err
+	}
+
 	// Validate cron if provided
 	if cfg.Cron != "" {
 		_, err := cron.ParseStandard(cfg.Cron)
@@ -60,6 +105,29 @@ func ValidateConfig(cfg Config) error {
 		}
 	}
 
+	if cfg.CronOverlap != "" && cfg.CronOverlap != "skip" && cfg.CronOverlap != "queue" {
+		return fmt.Errorf("cron_overlap must be either 'skip' or 'queue'")
+	}
+
+	if err := validateMaintenance(cfg); err != nil {
+		return err
+	}
+
+	if cfg.MaxRepoSize < 0 {
+		return fmt.Errorf("max_repo_size cannot be negative")
+	}
+	if cfg.OversizeAction != "" && cfg.OversizeAction != "skip" && cfg.OversizeAction != "shallow" {
+		return fmt.Errorf("max_repo_size_action must be either 'skip' or 'shallow'")
+	}
+	if cfg.MinFreeSpace < 0 {
+		return fmt.Errorf("min_free_space cannot be negative")
+	}
+
+	// Validate repository filters
+	if err := validateFilters(cfg); err != nil {
+		return err
+	}
+
 	// Validate raw git URLs if provided
 	for _, url := range cfg.RawGitURLs {
 		if err := validateGitURL(url); err != nil {
@@ -69,6 +137,14 @@ func ValidateConfig(cfg Config) error {
 
 	// If there are no raw git URLs, validate platform-specific configuration
 	if len(cfg.RawGitURLs) == 0 {
+		// The exec platform leaves discovery and credentials to the external program
+		if cfg.Platform == "exec" {
+			if cfg.Exec.Command == "" {
+				return fmt.Errorf("exec.command cannot be empty for the exec platform")
+			}
+			return nil
+		}
+
 		// Username is required for platform-specific sync
 		if cfg.Username == "" {
 			return fmt.Errorf("username cannot be empty when no raw git URLs are provided")
@@ -79,8 +155,10 @@ func ValidateConfig(cfg Config) error {
 			return fmt.Errorf("at least one token must be provided when no raw git URLs are provided. See here: https://github.com/AkashRajpurohit/git-sync/wiki/Configuration")
 		}
 
-		if cfg.Platform != "github" && cfg.Platform != "gitlab" && cfg.Platform != "bitbucket" && cfg.Platform != "forgejo" && cfg.Platform != "gitea" {
-			return fmt.Errorf("platform can only be `github`, `gitlab`, `bitbucket`, `forgejo` or `gitea` when no raw git URLs are provided")
+		switch cfg.Platform {
+		case "github", "gitlab", "bitbucket", "bitbucket-server", "forgejo", "gitea", "gogs", "azure-devops", "sourcehut":
+		default:
+			return fmt.Errorf("platform can only be `github`, `gitlab`, `bitbucket`, `bitbucket-server`, `forgejo`, `gitea`, `gogs`, `azure-devops`, `sourcehut` or `exec` when no raw git URLs are provided")
 		}
 
 		// Server configuration is required for platform-specific sync
@@ -92,6 +170,26 @@ func ValidateConfig(cfg Config) error {
 			return fmt.Errorf("server protocol can only be http or https")
 		}
 
+		// Starred and watched repositories are only available on GitHub
+		if (cfg.IncludeStarred || cfg.IncludeWatched) && cfg.Platform != "github" {
+			return fmt.Errorf("include_starred and include_watched are only supported for github")
+		}
+
+		if cfg.StarredMaxSizeMB < 0 {
+			return fmt.Errorf("starred_max_size_mb cannot be negative")
+		}
+
+		switch cfg.GitLab.MinAccessLevel {
+		case "", "guest", "reporter", "developer", "maintainer", "owner":
+		default:
+			return fmt.Errorf("gitlab.min_access_level can only be `guest`, `reporter`, `developer`, `maintainer` or `owner`")
+		}
+
+		// Sourcehut has users only
+		if cfg.Platform == "sourcehut" && len(cfg.Orgs) > 0 {
+			return fmt.Errorf("orgs are not supported for sourcehut, use users instead")
+		}
+
 		// Workspace is required only for Bitbucket
 		if cfg.Platform == "bitbucket" && cfg.Workspace == "" {
 			return fmt.Errorf("workspace cannot be empty for bitbucket")
@@ -100,3 +198,174 @@ func ValidateConfig(cfg Config) error {
 
 	return nil
 }
+
+var (
+	blobLimitPattern    = regexp.MustCompile(`^[0-9]+[kmg]?$`)
+	relativeDatePattern = regexp.MustCompile(`^[0-9]+ (day|week|month|year)s? ago$`)
+)
+
+// validateClone checks a clone type and the options it needs. prefix is
+// prepended to the option names in errors, such as overrides[0].
+func validateClone(prefix, cloneType string, options CloneOptions) error {
+	switch cloneType {
+	case "bare", "full", "mirror", "shallow", "blobless":
+	case "partial":
+		if options.BlobLimit == "" {
+			return fmt.Errorf("%sclone_options.blob_limit is required for the partial clone type", prefix)
+		}
+	case "shallow-since":
+		if options.ShallowSince == "" {
+			return fmt.Errorf("%sclone_options.shallow_since is required for the shallow-since clone type", prefix)
+		}
+	default:
+		return fmt.Errorf("%sclone_type can only be `bare`, `full`, `mirror`, `shallow`, `blobless`, `partial` or `shallow-since`", prefix)
+	}
+
+	if options.BlobLimit != "" && !blobLimitPattern.MatchString(strings.ToLower(options.BlobLimit)) {
+		return fmt.Errorf("%sclone_options.blob_limit must be a size like 512k, 1m or 1g", prefix)
+	}
+	if since := options.ShallowSince; since != "" && !relativeDatePattern.MatchString(since) {
+		if _, err := time.Parse(time.DateOnly, since); err != nil {
+			return fmt.Errorf("%sclone_options.shallow_since must be a YYYY-MM-DD date or like \"6 months ago\"", prefix)
+		}
+	}
+	return nil
+}
+
+func validateOverrides(cfg Config) error {
+	for i, override := range cfg.Overrides {
+		prefix := fmt.Sprintf("overrides[%d].", i)
+		if len(override.Repos) == 0 {
+			return fmt.Errorf("%srepos cannot be empty", prefix)
+		}
+
+		// The settings it leaves empty are taken from the global ones
+		repoCfg := override.Apply(cfg)
+		if err := validateClone(prefix, repoCfg.CloneType, repoCfg.CloneOptions); err != nil {
+			return err
+		}
+		if err := validateTimeouts(override.Timeouts); err != nil {
+			return fmt.Errorf("%s%v", prefix, err)
+		}
+		if err := validateTimeouts(repoCfg.Timeouts); err != nil {
+			return fmt.Errorf("%s%v", prefix, err)
+		}
+
+		if override.Directory != "" && !filepath.IsLocal(override.Directory) {
+			return fmt.Errorf("%sdirectory must be a relative path inside the backup directory", prefix)
+		}
+	}
+	return nil
+}
+
+func validateRetry(r RetryConfig) error {
+	if r.Backoff != "" && r.Backoff != "fixed" && r.Backoff != "exponential" {
+		return fmt.Errorf("retry.backoff must be either 'fixed' or 'exponential'")
+	}
+
+	if r.Delay < 0 || r.MaxDelay < 0 {
+		return fmt.Errorf("retry.delay and retry.max_delay cannot be negative")
+	}
+
+	return nil
+}
+
+func validateTimeouts(t TimeoutConfig) error {
+	for name, value := range map[string]int{
+		"clone":           t.Clone,
+		"fetch":           t.Fetch,
+		"wiki":            t.Wiki,
+		"api":             t.API,
+		"low_speed_limit": t.LowSpeedLimit,
+		"low_speed_time":  t.LowSpeedTime,
+	} {
+		if value < 0 {
+			return fmt.Errorf("timeouts.%s cannot be negative", name)
+		}
+	}
+
+	if (t.LowSpeedLimit > 0) != (t.LowSpeedTime > 0) {
+		return fmt.Errorf("timeouts.low_speed_limit and timeouts.low_speed_time must be set together")
+	}
+
+	return nil
+}
+
+// validateLimits checks l against the concurrency of the sync. Only that many
+// repositories are synced at once, so higher git and API concurrency limits
+// could never be reached.
+func validateLimits(l LimitsConfig, concurrency int) error {
+	all := []Limits{l.Limits}
+	for _, schedule := range l.Schedules {
+		all = append(all, schedule.Limits)
+	}
+	for _, limits := range all {
+		if limits.Bandwidth < 0 || limits.GitConcurrency < 0 || limits.APIConcurrency < 0 || limits.PerHost < 0 {
+			return fmt.Errorf("limits cannot be negative")
+		}
+		if limits.GitConcurrency > concurrency || limits.APIConcurrency > concurrency {
+			return fmt.Errorf("limits.git_concurrency and limits.api_concurrency cannot be more than concurrency (%d), which already caps them", concurrency)
+		}
+	}
+
+	for i, schedule := range l.Schedules {
+		start, err := parseClock(schedule.Start)
+		if err != nil {
+			return fmt.Errorf("limits.schedules[%d].start: %v", i, err)
+		}
+		end, err := parseClock(schedule.End)
+		if err != nil {
+			return fmt.Errorf("limits.schedules[%d].end: %v", i, err)
+		}
+		if start == end {
+			return fmt.Errorf("limits.schedules[%d] starts and ends at the same time", i)
+		}
+		for _, day := range schedule.Days {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("limits.schedules[%d]: invalid day %q, expected one of mon, tue, wed, thu, fri, sat or sun", i, day)
+			}
+		}
+	}
+
+	return nil
+}
+
+// pruneExpirePattern matches the grace periods prune_expire accepts. now is
+// left out, as it would prune objects a running fetch has just written.
+var pruneExpirePattern = regexp.MustCompile(`^([0-9]+\.(minute|hour|day|week|month|year)s?\.ago|never)$`)
+
+func validateMaintenance(cfg Config) error {
+	m := cfg.Maintenance
+	if m.Cron != "" {
+		if _, err := cron.ParseStandard(m.Cron); err != nil {
+			return fmt.Errorf("invalid maintenance.cron expression %s", m.Cron)
+		}
+		if cfg.Cron == "" {
+			return fmt.Errorf("maintenance.cron needs cron to be set, run `git-sync maintenance` to maintain the backups otherwise")
+		}
+	}
+
+	// Both are defaulted when left empty
+	if m.Concurrency < 0 || m.Concurrency > 20 {
+		return fmt.Errorf("maintenance.concurrency must be between 1 and 20")
+	}
+	if m.PruneExpire != "" && !pruneExpirePattern.MatchString(m.PruneExpire) {
+		return fmt.Errorf("maintenance.prune_expire must be a grace period like 2.weeks.ago, or never")
+	}
+	return nil
+}
+
+func validateQueue(q QueueConfig) error {
+	seen := make(map[string]bool)
+	for _, order := range q.Order {
+		if order != "priority" && order != "failed" && order != "pushed" {
+			return fmt.Errorf("queue.order can only contain `priority`, `failed` or `pushed`")
+		}
+		if seen[order] {
+			return fmt.Errorf("queue.order contains %s more than once", order)
+		}
+		seen[order] = true
+	}
+
+	return nil
+}
@@ -169,6 +169,639 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Invalid Shutdown Timeout - Negative",
+			cfg: Config{
+				BackupDir:       "test",
+				CloneType:       "bare",
+				Concurrency:     5,
+				ShutdownTimeout: -1,
+				Platform:        "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Timeouts - Negative Fetch",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Timeouts:    TimeoutConfig{Fetch: -1},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Timeouts - Low Speed Limit Without Time",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Timeouts:    TimeoutConfig{LowSpeedLimit: 1000},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Retry Backoff",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Retry:       RetryConfig{Count: 3, Delay: 5, Backoff: "linear"},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Retry Max Delay - Negative",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Retry:       RetryConfig{Count: 3, Delay: 5, MaxDelay: -1},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Cron Overlap",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Cron:        "0 0 * * *",
+				CronOverlap: "parallel",
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid Limits With Schedule",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Limits:      LimitsConfig{Limits: Limits{Bandwidth: 512}, Schedules: []LimitSchedule{{Days: []string{"Mon"}, Start: "09:00", End: "17:30", Limits: Limits{GitConcurrency: 1}}}},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid Limits - Negative Bandwidth",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Limits:      LimitsConfig{Limits: Limits{Bandwidth: -1}},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Limits - Schedule Time",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Limits:      LimitsConfig{Schedules: []LimitSchedule{{Start: "9am", End: "17:00"}}},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Limits - Schedule Day",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Limits:      LimitsConfig{Schedules: []LimitSchedule{{Days: []string{"monday"}, Start: "09:00", End: "17:00"}}},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Limits - Git Concurrency Above Concurrency",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Limits:      LimitsConfig{Limits: Limits{GitConcurrency: 6}},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Limits - Scheduled API Concurrency Above Concurrency",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Limits:      LimitsConfig{Schedules: []LimitSchedule{{Start: "09:00", End: "17:00", Limits: Limits{APIConcurrency: 10}}}},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid Queue",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Queue:       QueueConfig{Order: []string{"failed", "pushed"}, PriorityRepos: []string{"owner/*"}, Resume: true},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid Queue Order",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Queue:       QueueConfig{Order: []string{"alphabetical"}},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Queue Order - Duplicate",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Queue:       QueueConfig{Order: []string{"pushed", "pushed"}},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid Disk Quotas",
+			cfg: Config{
+				BackupDir:      "test",
+				CloneType:      "bare",
+				Concurrency:    5,
+				MaxRepoSize:    2048,
+				OversizeAction: "shallow",
+				MinFreeSpace:   10240,
+				Platform:       "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid Max Repo Size Action",
+			cfg: Config{
+				BackupDir:      "test",
+				CloneType:      "bare",
+				Concurrency:    5,
+				MaxRepoSize:    2048,
+				OversizeAction: "delete",
+				Platform:       "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Min Free Space",
+			cfg: Config{
+				BackupDir:    "test",
+				CloneType:    "bare",
+				Concurrency:  5,
+				MinFreeSpace: -1,
+				Platform:     "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid Maintenance",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Cron:        "0 * * * *",
+				Maintenance: MaintenanceConfig{Enabled: true, Cron: "0 3 * * 0", Concurrency: 2, PruneExpire: "2.weeks.ago"},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid Maintenance Prune Expire",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Maintenance: MaintenanceConfig{Enabled: true, PruneExpire: "now"},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Maintenance Concurrency",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Maintenance: MaintenanceConfig{Enabled: true, Concurrency: 50},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Maintenance Cron Without Cron",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Maintenance: MaintenanceConfig{Enabled: true, Cron: "0 3 * * 0"},
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid Blobless Clone Type",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "blobless",
+				Concurrency: 5,
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Valid Partial Clone Type",
+			cfg: Config{
+				BackupDir:    "test",
+				CloneType:    "partial",
+				CloneOptions: CloneOptions{BlobLimit: "512k"},
+				Concurrency:  5,
+				Platform:     "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Partial Clone Type Without Blob Limit",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "partial",
+				Concurrency: 5,
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Blob Limit",
+			cfg: Config{
+				BackupDir:    "test",
+				CloneType:    "partial",
+				CloneOptions: CloneOptions{BlobLimit: "1mb"},
+				Concurrency:  5,
+				Platform:     "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid Shallow Since Date",
+			cfg: Config{
+				BackupDir:    "test",
+				CloneType:    "shallow-since",
+				CloneOptions: CloneOptions{ShallowSince: "2024-01-31"},
+				Concurrency:  5,
+				Platform:     "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Valid Relative Shallow Since",
+			cfg: Config{
+				BackupDir:    "test",
+				CloneType:    "shallow-since",
+				CloneOptions: CloneOptions{ShallowSince: "6 months ago"},
+				Concurrency:  5,
+				Platform:     "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid Shallow Since",
+			cfg: Config{
+				BackupDir:    "test",
+				CloneType:    "shallow-since",
+				CloneOptions: CloneOptions{ShallowSince: "last year"},
+				Concurrency:  5,
+				Platform:     "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid Clone Type Override",
+			cfg: Config{
+				BackupDir:    "test",
+				CloneType:    "bare",
+				CloneOptions: CloneOptions{BlobLimit: "1m"},
+				Overrides:    []Override{{Repos: []string{"owner/monorepo"}, CloneType: "partial"}},
+				Concurrency:  5,
+				Platform:     "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Override Without Repos",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Overrides:   []Override{{CloneType: "blobless"}},
+				Concurrency: 5,
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Override Clone Type",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Overrides:   []Override{{Repos: []string{"monorepo"}, CloneType: "sparse"}},
+				Concurrency: 5,
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Override Missing Clone Option",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Overrides:   []Override{{Repos: []string{"monorepo"}, CloneType: "shallow-since"}},
+				Concurrency: 5,
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid Override",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Overrides:   []Override{{Repos: []string{"acme/*"}, Timeouts: TimeoutConfig{Clone: 3600}, Priority: true, Directory: "acme"}},
+				Concurrency: 5,
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Override Directory Outside Backup Dir",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Overrides:   []Override{{Repos: []string{"acme/*"}, Directory: "../elsewhere"}},
+				Concurrency: 5,
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative Override Timeout",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Overrides:   []Override{{Repos: []string{"acme/*"}, Timeouts: TimeoutConfig{Fetch: -1}}},
+				Concurrency: 5,
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Override Low Speed Limit Without Time",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Overrides:   []Override{{Repos: []string{"acme/*"}, Timeouts: TimeoutConfig{LowSpeedLimit: 1000}}},
+				Concurrency: 5,
+				Platform:    "github",
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+				Username: "test",
+				Tokens:   []string{"token1"},
+			},
+			wantErr: true,
+		},
 		{
 			name: "Empty BackupDir",
 			cfg: Config{
@@ -292,6 +925,62 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Valid Bitbucket Server Config without Workspace",
+			cfg: Config{
+				Username:    "test",
+				Tokens:      []string{"token1"},
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Platform:    "bitbucket-server",
+				Server: Server{
+					Domain:   "bitbucket.example.com",
+					Protocol: "https",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Orgs for Sourcehut",
+			cfg: Config{
+				Username:    "test",
+				Tokens:      []string{"token1"},
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Platform:    "sourcehut",
+				Server: Server{
+					Domain:   "git.sr.ht",
+					Protocol: "https",
+				},
+				Orgs: []string{"someorg"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid Exec Config without Username or Tokens",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Platform:    "exec",
+				Exec: ExecConfig{
+					Command: "/usr/local/bin/list-repos",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Exec Config without Command",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Platform:    "exec",
+			},
+			wantErr: true,
+		},
 		{
 			name: "Valid Mixed Config (Platform + Raw URLs)",
 			cfg: Config{
@@ -312,6 +1001,103 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Invalid Filter Visibility",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Filters:     FilterConfig{Visibility: []string{"secret"}},
+				RawGitURLs:  []string{"https://github.com/user/repo1.git"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Min Size Greater Than Max Size",
+			cfg: Config{
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Filters:     FilterConfig{MinSizeMB: 10, MaxSizeMB: 5},
+				RawGitURLs:  []string{"https://github.com/user/repo1.git"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Include Starred on Non-GitHub Platform",
+			cfg: Config{
+				Username:       "test",
+				Tokens:         []string{"token1"},
+				BackupDir:      "test",
+				CloneType:      "bare",
+				Concurrency:    5,
+				Platform:       "gitlab",
+				IncludeStarred: true,
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Include Starred and Watched on GitHub",
+			cfg: Config{
+				Username:         "test",
+				Tokens:           []string{"token1"},
+				BackupDir:        "test",
+				CloneType:        "bare",
+				Concurrency:      5,
+				Platform:         "github",
+				IncludeStarred:   true,
+				IncludeWatched:   true,
+				StarredMaxSizeMB: 500,
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid GitLab Min Access Level",
+			cfg: Config{
+				Username:    "test",
+				Tokens:      []string{"token1"},
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Platform:    "gitlab",
+				GitLab:      GitLabConfig{MinAccessLevel: "admin"},
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid GitLab Group Options",
+			cfg: Config{
+				Username:    "test",
+				Tokens:      []string{"token1"},
+				BackupDir:   "test",
+				CloneType:   "bare",
+				Concurrency: 5,
+				Platform:    "gitlab",
+				GitLab: GitLabConfig{
+					MinAccessLevel:   "developer",
+					Groups:           []string{"my-group", "1234"},
+					IncludeSubgroups: true,
+					IncludeShared:    true,
+				},
+				Server: Server{
+					Domain:   "test",
+					Protocol: "https",
+				},
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,280 @@
+// Package filter decides which discovered repositories are backed up.
+//
+// Every platform client hands its repositories to the same Filter so that the
+// include/exclude options behave identically everywhere. Rules are evaluated
+// in the order below and the first rule that rejects a repository wins:
+//
+//  1. exclude_orgs              - owner matches a pattern
+//  2. include_orgs              - when set, owner must match a pattern
+//  3. exclude_repos             - name or owner/name matches a pattern
+//  4. include_repos             - when set, name or owner/name must match a pattern
+//  5. include_forks             - forks are skipped unless enabled or matched by include_orgs or include_repos
+//  6. filters.exclude_archived  - archived repositories are skipped
+//  7. filters.visibility        - when set, visibility must be listed
+//  8. filters.exclude_topics    - any topic matches a pattern
+//  9. filters.include_topics    - when set, at least one topic must match a pattern
+//  10. filters.languages        - when set, primary language must be listed
+//  11. filters.max_inactive_days - last push must be recent enough
+//  12. filters.min_size_mb / filters.max_size_mb
+//
+// Patterns are shell globs (see filepath.Match) unless prefixed with
+// "regex:", in which case the remainder is a regular expression that must
+// match the whole value. The org rules only apply on platforms that tell the
+// owners of repositories apart, GitHub and GitLab. There include_orgs never
+// selects repositories of users and exclude_orgs never excludes them, as
+// their owners are not organizations or groups. Attributes a platform does
+// not report (empty visibility, language, zero push time or size) never
+// exclude a repository.
+package filter
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+const regexPrefix = "regex:"
+
+// Repository is the normalized repository every platform client discovers.
+type Repository = client.Repository
+
+// Decision is the outcome of evaluating a repository. Rule names the config
+// option that excluded it, or the include list that selected it.
+type Decision struct {
+	Included bool
+	Rule     string
+}
+
+type pattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func (p pattern) match(value string) bool {
+	if p.re != nil {
+		return p.re.MatchString(value)
+	}
+	match, err := filepath.Match(p.glob, value)
+	return err == nil && match
+}
+
+func compilePatterns(option string, list []string) ([]pattern, error) {
+	patterns := make([]pattern, 0, len(list))
+	for _, raw := range list {
+		if expr, ok := strings.CutPrefix(raw, regexPrefix); ok {
+			re, err := regexp.Compile("^(?:" + expr + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex in %s: %s: %v", option, raw, err)
+			}
+			patterns = append(patterns, pattern{re: re})
+			continue
+		}
+		if _, err := filepath.Match(raw, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob in %s: %s: %v", option, raw, err)
+		}
+		patterns = append(patterns, pattern{glob: raw})
+	}
+	return patterns, nil
+}
+
+func matchAny(patterns []pattern, values ...string) bool {
+	for _, p := range patterns {
+		for _, v := range values {
+			if p.match(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Patterns is a list of patterns in the syntax of the filter options, for
+// options outside of the filter that select repositories
+type Patterns []pattern
+
+// Compile compiles the patterns of a config option
+func Compile(option string, list []string) (Patterns, error) {
+	patterns, err := compilePatterns(option, list)
+	return Patterns(patterns), err
+}
+
+// Match reports whether any of the patterns matches any of the values
+func (p Patterns) Match(values ...string) bool {
+	return matchAny(p, values...)
+}
+
+type Filter struct {
+	includeOrgs     []pattern
+	excludeOrgs     []pattern
+	includeRepos    []pattern
+	excludeRepos    []pattern
+	includeTopics   []pattern
+	excludeTopics   []pattern
+	includeForks    bool
+	excludeArchived bool
+	visibility      []string
+	languages       []string
+	maxInactive     time.Duration
+	minSizeKB       int64
+	maxSizeKB       int64
+	now             func() time.Time
+}
+
+func New(cfg config.Config) (*Filter, error) {
+	f := &Filter{
+		includeForks:    cfg.IncludeForks,
+		excludeArchived: cfg.Filters.ExcludeArchived,
+		visibility:      cfg.Filters.Visibility,
+		languages:       cfg.Filters.Languages,
+		maxInactive:     time.Duration(cfg.Filters.MaxInactiveDays) * 24 * time.Hour,
+		minSizeKB:       int64(cfg.Filters.MinSizeMB) * 1024,
+		maxSizeKB:       int64(cfg.Filters.MaxSizeMB) * 1024,
+		now:             time.Now,
+	}
+
+	lists := []struct {
+		option string
+		values []string
+		target *[]pattern
+	}{
+		{"include_orgs", cfg.IncludeOrgs, &f.includeOrgs},
+		{"exclude_orgs", cfg.ExcludeOrgs, &f.excludeOrgs},
+		{"include_repos", cfg.IncludeRepos, &f.includeRepos},
+		{"exclude_repos", cfg.ExcludeRepos, &f.excludeRepos},
+		{"filters.include_topics", cfg.Filters.IncludeTopics, &f.includeTopics},
+		{"filters.exclude_topics", cfg.Filters.ExcludeTopics, &f.excludeTopics},
+	}
+	for _, l := range lists {
+		patterns, err := compilePatterns(l.option, l.values)
+		if err != nil {
+			return nil, err
+		}
+		*l.target = patterns
+	}
+
+	return f, nil
+}
+
+// Validate checks that every pattern of cfg compiles: those of the filters,
+// the overrides and the queue priorities. The config package cannot check
+// them itself, as they are compiled here.
+func Validate(cfg config.Config) error {
+	if _, err := New(cfg); err != nil {
+		return err
+	}
+	if _, err := CompileOverrides(cfg.Overrides); err != nil {
+		return err
+	}
+	if _, err := Compile("queue.priority_repos", cfg.Queue.PriorityRepos); err != nil {
+		return err
+	}
+	_, err := Compile("queue.priority_topics", cfg.Queue.PriorityTopics)
+	return err
+}
+
+// Evaluate runs the repository through every rule in precedence order.
+func (f *Filter) Evaluate(repo Repository) Decision {
+	if repo.OwnerKind == client.OwnerOrg && matchAny(f.excludeOrgs, repo.Owner) {
+		return Decision{Rule: "exclude_orgs"}
+	}
+	includeOrgs := repo.OwnerKind != client.OwnerUnknown && len(f.includeOrgs) > 0
+	if includeOrgs && (repo.OwnerKind == client.OwnerUser || !matchAny(f.includeOrgs, repo.Owner)) {
+		return Decision{Rule: "include_orgs"}
+	}
+
+	if matchAny(f.excludeRepos, repo.Name, repo.FullName()) {
+		return Decision{Rule: "exclude_repos"}
+	}
+	explicitlyIncluded := false
+	if len(f.includeRepos) > 0 {
+		if !matchAny(f.includeRepos, repo.Name, repo.FullName()) {
+			return Decision{Rule: "include_repos"}
+		}
+		explicitlyIncluded = true
+	}
+
+	if repo.Fork && !f.includeForks && !explicitlyIncluded && !includeOrgs {
+		return Decision{Rule: "include_forks"}
+	}
+
+	if f.excludeArchived && repo.Archived {
+		return Decision{Rule: "filters.exclude_archived"}
+	}
+
+	if len(f.visibility) > 0 && repo.Visibility != "" && !containsFold(f.visibility, repo.Visibility) {
+		return Decision{Rule: "filters.visibility"}
+	}
+
+	if matchAny(f.excludeTopics, repo.Topics...) {
+		return Decision{Rule: "filters.exclude_topics"}
+	}
+	if len(f.includeTopics) > 0 && !matchAny(f.includeTopics, repo.Topics...) {
+		return Decision{Rule: "filters.include_topics"}
+	}
+
+	if len(f.languages) > 0 && repo.Language != "" && !containsFold(f.languages, repo.Language) {
+		return Decision{Rule: "filters.languages"}
+	}
+
+	if f.maxInactive > 0 && !repo.PushedAt.IsZero() && f.now().Sub(repo.PushedAt) > f.maxInactive {
+		return Decision{Rule: "filters.max_inactive_days"}
+	}
+
+	if repo.SizeKB > 0 {
+		if f.minSizeKB > 0 && repo.SizeKB < f.minSizeKB {
+			return Decision{Rule: "filters.min_size_mb"}
+		}
+		if f.maxSizeKB > 0 && repo.SizeKB > f.maxSizeKB {
+			return Decision{Rule: "filters.max_size_mb"}
+		}
+	}
+
+	if explicitlyIncluded {
+		return Decision{Included: true, Rule: "include_repos"}
+	}
+	if includeOrgs {
+		return Decision{Included: true, Rule: "include_orgs"}
+	}
+	return Decision{Included: true}
+}
+
+// EvaluateExcludes runs the repository through the exclude_orgs and
+// exclude_repos rules only. Containers are not selected by the include rules,
+// they follow the repositories stored beneath them.
+func (f *Filter) EvaluateExcludes(repo Repository) Decision {
+	if repo.OwnerKind == client.OwnerOrg && matchAny(f.excludeOrgs, repo.Owner) {
+		return Decision{Rule: "exclude_orgs"}
+	}
+	if matchAny(f.excludeRepos, repo.Name, repo.FullName()) {
+		return Decision{Rule: "exclude_repos"}
+	}
+	return Decision{Included: true}
+}
+
+// Decide evaluates the repository and logs the decision at debug level.
+func (f *Filter) Decide(repo Repository) Decision {
+	decision := f.Evaluate(repo)
+	if !decision.Included {
+		logger.Debugf("[%s] Repo excluded: %s", decision.Rule, repo.FullName())
+	} else if decision.Rule != "" {
+		logger.Debugf("[%s] Repo included: %s", decision.Rule, repo.FullName())
+	} else {
+		logger.Debug("Repo included: ", repo.FullName())
+	}
+	return decision
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
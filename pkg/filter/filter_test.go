@@ -0,0 +1,266 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		cfg          config.Config
+		repo         Repository
+		wantIncluded bool
+		wantRule     string
+	}{
+		{
+			name:         "No filters",
+			repo:         Repository{Owner: "alice", Name: "repo"},
+			wantIncluded: true,
+		},
+		{
+			name:         "Exclude orgs glob",
+			cfg:          config.Config{ExcludeOrgs: []string{"acme-*"}},
+			repo:         Repository{Owner: "acme-labs", OwnerKind: client.OwnerOrg, Name: "repo"},
+			wantIncluded: false,
+			wantRule:     "exclude_orgs",
+		},
+		{
+			name:         "Include orgs rejects other owners",
+			cfg:          config.Config{IncludeOrgs: []string{"acme"}},
+			repo:         Repository{Owner: "other", OwnerKind: client.OwnerOrg, Name: "repo"},
+			wantIncluded: false,
+			wantRule:     "include_orgs",
+		},
+		{
+			name:         "Include orgs selects matching owner",
+			cfg:          config.Config{IncludeOrgs: []string{"acme"}},
+			repo:         Repository{Owner: "acme", OwnerKind: client.OwnerOrg, Name: "repo"},
+			wantIncluded: true,
+			wantRule:     "include_orgs",
+		},
+		{
+			name:         "Include orgs never selects users",
+			cfg:          config.Config{IncludeOrgs: []string{"*"}},
+			repo:         Repository{Owner: "alice", OwnerKind: client.OwnerUser, Name: "repo"},
+			wantIncluded: false,
+			wantRule:     "include_orgs",
+		},
+		{
+			name:         "Exclude orgs never excludes users",
+			cfg:          config.Config{ExcludeOrgs: []string{"alice"}},
+			repo:         Repository{Owner: "alice", OwnerKind: client.OwnerUser, Name: "repo"},
+			wantIncluded: true,
+		},
+		{
+			name:         "Include orgs ignored when the owner kind is unknown",
+			cfg:          config.Config{IncludeOrgs: []string{"acme"}},
+			repo:         Repository{Owner: "alice", Name: "repo"},
+			wantIncluded: true,
+		},
+		{
+			name:         "Exclude orgs ignored when the owner kind is unknown",
+			cfg:          config.Config{ExcludeOrgs: []string{"acme"}},
+			repo:         Repository{Owner: "acme", Name: "repo"},
+			wantIncluded: true,
+		},
+		{
+			name:         "Exclude repos matches full name",
+			cfg:          config.Config{ExcludeRepos: []string{"alice/secret-*"}},
+			repo:         Repository{Owner: "alice", Name: "secret-notes"},
+			wantIncluded: false,
+			wantRule:     "exclude_repos",
+		},
+		{
+			name:         "Exclude wins over include",
+			cfg:          config.Config{IncludeRepos: []string{"*"}, ExcludeRepos: []string{"repo"}},
+			repo:         Repository{Owner: "alice", Name: "repo"},
+			wantIncluded: false,
+			wantRule:     "exclude_repos",
+		},
+		{
+			name:         "Include repos regex",
+			cfg:          config.Config{IncludeRepos: []string{"regex:^(api|web)-[0-9]+$"}},
+			repo:         Repository{Owner: "alice", Name: "api-42"},
+			wantIncluded: true,
+			wantRule:     "include_repos",
+		},
+		{
+			name:         "Regex must match the whole value",
+			cfg:          config.Config{IncludeRepos: []string{"regex:api"}},
+			repo:         Repository{Owner: "alice", Name: "api-42"},
+			wantIncluded: false,
+			wantRule:     "include_repos",
+		},
+		{
+			name:         "Forks excluded by default",
+			repo:         Repository{Owner: "alice", Name: "repo", Fork: true},
+			wantIncluded: false,
+			wantRule:     "include_forks",
+		},
+		{
+			name:         "Forks included when explicitly listed",
+			cfg:          config.Config{IncludeRepos: []string{"repo"}},
+			repo:         Repository{Owner: "alice", Name: "repo", Fork: true},
+			wantIncluded: true,
+			wantRule:     "include_repos",
+		},
+		{
+			name:         "Forks included when their org is",
+			cfg:          config.Config{IncludeOrgs: []string{"acme"}},
+			repo:         Repository{Owner: "acme", OwnerKind: client.OwnerOrg, Name: "repo", Fork: true},
+			wantIncluded: true,
+			wantRule:     "include_orgs",
+		},
+		{
+			name:         "Archived excluded",
+			cfg:          config.Config{Filters: config.FilterConfig{ExcludeArchived: true}},
+			repo:         Repository{Owner: "alice", Name: "repo", Archived: true},
+			wantIncluded: false,
+			wantRule:     "filters.exclude_archived",
+		},
+		{
+			name:         "Visibility not listed",
+			cfg:          config.Config{Filters: config.FilterConfig{Visibility: []string{"private"}}},
+			repo:         Repository{Owner: "alice", Name: "repo", Visibility: "public"},
+			wantIncluded: false,
+			wantRule:     "filters.visibility",
+		},
+		{
+			name:         "Unknown visibility is not excluded",
+			cfg:          config.Config{Filters: config.FilterConfig{Visibility: []string{"private"}}},
+			repo:         Repository{Owner: "alice", Name: "repo"},
+			wantIncluded: true,
+		},
+		{
+			name:         "Exclude topics",
+			cfg:          config.Config{Filters: config.FilterConfig{ExcludeTopics: []string{"deprecated"}}},
+			repo:         Repository{Owner: "alice", Name: "repo", Topics: []string{"go", "deprecated"}},
+			wantIncluded: false,
+			wantRule:     "filters.exclude_topics",
+		},
+		{
+			name:         "Include topics without a match",
+			cfg:          config.Config{Filters: config.FilterConfig{IncludeTopics: []string{"backup-*"}}},
+			repo:         Repository{Owner: "alice", Name: "repo", Topics: []string{"go"}},
+			wantIncluded: false,
+			wantRule:     "filters.include_topics",
+		},
+		{
+			name:         "Languages are case insensitive",
+			cfg:          config.Config{Filters: config.FilterConfig{Languages: []string{"go"}}},
+			repo:         Repository{Owner: "alice", Name: "repo", Language: "Go"},
+			wantIncluded: true,
+		},
+		{
+			name:         "Inactive repository",
+			cfg:          config.Config{Filters: config.FilterConfig{MaxInactiveDays: 30}},
+			repo:         Repository{Owner: "alice", Name: "repo", PushedAt: now.AddDate(0, -2, 0)},
+			wantIncluded: false,
+			wantRule:     "filters.max_inactive_days",
+		},
+		{
+			name:         "Too large",
+			cfg:          config.Config{Filters: config.FilterConfig{MaxSizeMB: 1}},
+			repo:         Repository{Owner: "alice", Name: "repo", SizeKB: 2048},
+			wantIncluded: false,
+			wantRule:     "filters.max_size_mb",
+		},
+		{
+			name:         "Too small",
+			cfg:          config.Config{Filters: config.FilterConfig{MinSizeMB: 1}},
+			repo:         Repository{Owner: "alice", Name: "repo", SizeKB: 10},
+			wantIncluded: false,
+			wantRule:     "filters.min_size_mb",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger.InitLogger("fatal")
+			f, err := New(tt.cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			f.now = func() time.Time { return now }
+
+			got := f.Evaluate(tt.repo)
+			if got.Included != tt.wantIncluded {
+				t.Errorf("Included = %v, want %v", got.Included, tt.wantIncluded)
+			}
+			if got.Rule != tt.wantRule {
+				t.Errorf("Rule = %q, want %q", got.Rule, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestNewInvalidPattern(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+	}{
+		{
+			name: "Invalid regex",
+			cfg:  config.Config{IncludeRepos: []string{"regex:("}},
+		},
+		{
+			name: "Invalid glob",
+			cfg:  config.Config{ExcludeOrgs: []string{"[abc"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); err == nil {
+				t.Error("New() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		wantErr bool
+	}{
+		{
+			name: "Valid patterns",
+			cfg: config.Config{
+				IncludeRepos: []string{"regex:^api-.*$"},
+				Overrides:    []config.Override{{Repos: []string{"acme/*"}}},
+				Queue:        config.QueueConfig{PriorityTopics: []string{"backup-*"}},
+			},
+		},
+		{
+			name:    "Invalid regex in include_repos",
+			cfg:     config.Config{IncludeRepos: []string{"regex:("}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid glob in an override",
+			cfg:     config.Config{Overrides: []config.Override{{Repos: []string{"[abc"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid regex in queue.priority_topics",
+			cfg:     config.Config{Queue: config.QueueConfig{PriorityTopics: []string{"regex:("}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+)
+
+// Overrides applies the overrides section of the config to the repositories
+// it matches
+type Overrides struct {
+	overrides []config.Override
+	repos     []Patterns
+}
+
+// CompileOverrides compiles the repository patterns of the overrides
+func CompileOverrides(overrides []config.Override) (*Overrides, error) {
+	o := &Overrides{overrides: overrides}
+	for i, override := range overrides {
+		repos, err := Compile(fmt.Sprintf("overrides[%d].repos", i), override.Repos)
+		if err != nil {
+			return nil, err
+		}
+		o.repos = append(o.repos, repos)
+	}
+	return o, nil
+}
+
+// Apply returns cfg with the first override matching the name or owner/name
+// of repo applied, or cfg unchanged when none does
+func (o *Overrides) Apply(cfg config.Config, repo Repository) config.Config {
+	if override := o.match(repo); override != nil {
+		return override.Apply(cfg)
+	}
+	return cfg
+}
+
+// Prioritized reports whether the override matching repo gives it priority
+func (o *Overrides) Prioritized(repo Repository) bool {
+	override := o.match(repo)
+	return override != nil && override.Priority
+}
+
+func (o *Overrides) match(repo Repository) *config.Override {
+	for i, repos := range o.repos {
+		if repos.Match(repo.Name, repo.FullName()) {
+			return &o.overrides[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+)
+
+func TestOverridesApply(t *testing.T) {
+	cfg := config.Config{
+		CloneType:    "bare",
+		CloneOptions: config.CloneOptions{BlobLimit: "1m"},
+		Overrides: []config.Override{
+			{Repos: []string{"acme/monorepo"}, CloneType: "partial"},
+			{Repos: []string{"regex:.*-archive"}, CloneType: "shallow-since", CloneOptions: config.CloneOptions{ShallowSince: "2024-01-01"}},
+			{Repos: []string{"*"}, CloneType: "mirror"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		repo          Repository
+		wantCloneType string
+		wantOptions   config.CloneOptions
+	}{
+		{
+			name:          "Full name match keeps global options",
+			repo:          Repository{Owner: "acme", Name: "monorepo"},
+			wantCloneType: "partial",
+			wantOptions:   config.CloneOptions{BlobLimit: "1m"},
+		},
+		{
+			name:          "Regex match sets options",
+			repo:          Repository{Owner: "acme", Name: "logs-archive"},
+			wantCloneType: "shallow-since",
+			wantOptions:   config.CloneOptions{BlobLimit: "1m", ShallowSince: "2024-01-01"},
+		},
+		{
+			name:          "First match wins",
+			repo:          Repository{Owner: "alice", Name: "monorepo"},
+			wantCloneType: "mirror",
+			wantOptions:   config.CloneOptions{BlobLimit: "1m"},
+		},
+	}
+
+	overrides, err := CompileOverrides(cfg.Overrides)
+	if err != nil {
+		t.Fatalf("CompileOverrides() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := overrides.Apply(cfg, tt.repo)
+			if got.CloneType != tt.wantCloneType {
+				t.Errorf("CloneType = %q, want %q", got.CloneType, tt.wantCloneType)
+			}
+			if got.CloneOptions != tt.wantOptions {
+				t.Errorf("CloneOptions = %+v, want %+v", got.CloneOptions, tt.wantOptions)
+			}
+		})
+	}
+}
+
+func TestOverridesApplyNoMatch(t *testing.T) {
+	cfg := config.Config{
+		CloneType: "bare",
+		Overrides: []config.Override{{Repos: []string{"monorepo"}, CloneType: "blobless"}},
+	}
+
+	overrides, err := CompileOverrides(cfg.Overrides)
+	if err != nil {
+		t.Fatalf("CompileOverrides() error = %v", err)
+	}
+	if got := overrides.Apply(cfg, Repository{Owner: "alice", Name: "repo"}); got.CloneType != "bare" {
+		t.Errorf("CloneType = %q, want bare", got.CloneType)
+	}
+}
+
+func TestCompileOverridesInvalidPattern(t *testing.T) {
+	if _, err := CompileOverrides([]config.Override{{Repos: []string{"regex:("}}}); err == nil {
+		t.Error("CompileOverrides() expected error, got nil")
+	}
+}
+
+func TestOverridesPrioritized(t *testing.T) {
+	overrides, err := CompileOverrides([]config.Override{
+		{Repos: []string{"acme/legacy-*"}},
+		{Repos: []string{"acme/*"}, Priority: true},
+	})
+	if err != nil {
+		t.Fatalf("CompileOverrides() error = %v", err)
+	}
+
+	tests := []struct {
+		repo Repository
+		want bool
+	}{
+		{repo: Repository{Owner: "acme", Name: "app"}, want: true},
+		{repo: Repository{Owner: "acme", Name: "legacy-app"}, want: false},
+		{repo: Repository{Owner: "alice", Name: "app"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repo.FullName(), func(t *testing.T) {
+			if got := overrides.Prioritized(tt.repo); got != tt.want {
+				t.Errorf("Prioritized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
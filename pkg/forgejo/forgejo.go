@@ -1,13 +1,14 @@
 package forgejo
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 
 	fg "codeberg.org/mvdkleijn/forgejo-sdk/forgejo"
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
 	"github.com/AkashRajpurohit/git-sync/pkg/config"
-	"github.com/AkashRajpurohit/git-sync/pkg/helpers"
 	"github.com/AkashRajpurohit/git-sync/pkg/logger"
-	gitSync "github.com/AkashRajpurohit/git-sync/pkg/sync"
 	"github.com/AkashRajpurohit/git-sync/pkg/token"
 )
 
@@ -27,86 +28,86 @@ func (c *ForgejoClient) GetTokenManager() *token.Manager {
 	return c.tokenManager
 }
 
-func (c *ForgejoClient) createClient() (*fg.Client, error) {
+func (c *ForgejoClient) createClient(ctx context.Context) (*fg.Client, error) {
 	client, err := fg.NewClient(
 		fmt.Sprintf("%s://%s", c.serverConfig.Protocol, c.serverConfig.Domain),
-		fg.SetToken(c.tokenManager.GetNextToken()))
+		fg.SetToken(c.tokenManager.GetNextToken()),
+		fg.SetContext(ctx))
 	if err != nil {
 		return nil, err
 	}
 	return client, nil
 }
 
-func (c *ForgejoClient) Sync(cfg config.Config) error {
-	repos, err := c.getUserRepos(cfg)
+func (c *ForgejoClient) ListRepositories(ctx context.Context, cfg config.Config) ([]client.Repository, error) {
+	repos, err := c.listRepos(ctx, cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	gitSync.LogRepoCount(len(repos), cfg.Platform)
-
-	gitSync.SyncWithConcurrency(cfg, repos, func(repo *fg.Repository) {
-		gitSync.CloneOrUpdateRepo(repo.Owner.UserName, repo.Name, cfg)
-		if cfg.IncludeWiki && repo.HasWiki {
-			gitSync.SyncWiki(repo.Owner.UserName, repo.Name, cfg)
-		}
-	})
+	allRepos := make([]client.Repository, 0, len(repos))
+	for _, repo := range repos {
+		allRepos = append(allRepos, toRepository(repo))
+	}
 
-	gitSync.LogSyncSummary(&cfg)
-	return nil
+	return allRepos, nil
 }
 
-func (c *ForgejoClient) getUserRepos(cfg config.Config) ([]*fg.Repository, error) {
-	logger.Debug("Fetching list of repositories ⏳")
-	client, err := c.createClient()
+// listRepos returns every repository of the authenticated user, plus those of
+// the configured users and orgs, before any filtering
+func (c *ForgejoClient) listRepos(ctx context.Context, cfg config.Config) ([]*fg.Repository, error) {
+	repos, err := c.listUserRepos(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var allRepos []*fg.Repository
-	pageOpt := fg.ListOptions{
-		PageSize: 100,
+	for _, user := range cfg.Users {
+		logger.Debugf("Fetching list of repositories for user %s ⏳", user)
+		userRepos, err := c.listPaged(ctx, user, func(client *fg.Client, opt fg.ListOptions) ([]*fg.Repository, *fg.Response, error) {
+			return client.ListUserRepos(user, fg.ListReposOptions{ListOptions: opt})
+		})
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, userRepos...)
 	}
 
-	for {
-		repos, resp, err := client.ListMyRepos(fg.ListReposOptions{ListOptions: pageOpt})
+	for _, org := range cfg.Orgs {
+		logger.Debugf("Fetching list of repositories for org %s ⏳", org)
+		orgRepos, err := c.listPaged(ctx, org, func(client *fg.Client, opt fg.ListOptions) ([]*fg.Repository, *fg.Response, error) {
+			return client.ListOrgRepos(org, fg.ListOrgReposOptions{ListOptions: opt})
+		})
 		if err != nil {
-			logger.Debugf("Error with current token, trying next token: %v", err)
-			client, err = c.createClient()
-			if err != nil {
-				return nil, err
-			}
-			continue
+			return nil, err
 		}
+		repos = append(repos, orgRepos...)
+	}
 
-		var reposToInclude []*fg.Repository
-		for _, repo := range repos {
-			if len(cfg.IncludeRepos) > 0 {
-				if helpers.IsIncludedInList(cfg.IncludeRepos, repo.FullName) {
-					logger.Debug("[include_repos] Repo included: ", repo.Name)
-					reposToInclude = append(reposToInclude, repo)
-				}
+	return repos, nil
+}
 
-				continue
-			}
+// listPaged walks every page of a repository listing. Each page is a request
+// of its own that tries every token before giving up, see client.WithTokens.
+func (c *ForgejoClient) listPaged(ctx context.Context, target string, list func(*fg.Client, fg.ListOptions) ([]*fg.Repository, *fg.Response, error)) ([]*fg.Repository, error) {
+	pageOpt := fg.ListOptions{PageSize: 100}
 
-			if len(cfg.ExcludeRepos) > 0 {
-				if helpers.IsIncludedInList(cfg.ExcludeRepos, repo.FullName) {
-					logger.Debug("[exclude_repos] Repo excluded: ", repo.Name)
-					continue
-				}
-			}
-
-			if !cfg.IncludeForks && repo.Fork {
-				logger.Debug("[include_forks] Repo excluded: ", repo.Name)
-				continue
+	var allRepos []*fg.Repository
+	for {
+		var repos []*fg.Repository
+		var resp *fg.Response
+		err := client.WithTokens(ctx, c.tokenManager, func(ctx context.Context) error {
+			fgClient, err := c.createClient(ctx)
+			if err != nil {
+				return err
 			}
-
-			logger.Debug("Repo included: ", repo.Name)
-			reposToInclude = append(reposToInclude, repo)
+			repos, resp, err = list(fgClient, pageOpt)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories for %s: %w", target, err)
 		}
 
-		allRepos = append(allRepos, reposToInclude...)
+		allRepos = append(allRepos, repos...)
 		if resp.NextPage == 0 {
 			break
 		}
@@ -117,3 +118,36 @@ func (c *ForgejoClient) getUserRepos(cfg config.Config) ([]*fg.Repository, error
 
 	return allRepos, nil
 }
+
+// listUserRepos returns every repository of the authenticated user before any filtering
+func (c *ForgejoClient) listUserRepos(ctx context.Context) ([]*fg.Repository, error) {
+	logger.Debug("Fetching list of repositories ⏳")
+	return c.listPaged(ctx, "the authenticated user", func(client *fg.Client, opt fg.ListOptions) ([]*fg.Repository, *fg.Response, error) {
+		return client.ListMyRepos(fg.ListReposOptions{ListOptions: opt})
+	})
+}
+
+func toRepository(repo *fg.Repository) client.Repository {
+	visibility := "public"
+	if repo.Private {
+		visibility = "private"
+	} else if repo.Internal {
+		visibility = "internal"
+	}
+
+	r := client.Repository{
+		ID:         strconv.FormatInt(repo.ID, 10),
+		Owner:      repo.Owner.UserName,
+		Name:       repo.Name,
+		HasWiki:    repo.HasWiki,
+		Fork:       repo.Fork,
+		Archived:   repo.Archived,
+		Visibility: visibility,
+		PushedAt:   repo.Updated,
+		SizeKB:     int64(repo.Size),
+	}
+	if repo.Parent != nil {
+		r.ForkOf = repo.Parent.FullName
+	}
+	return r
+}
@@ -2,16 +2,16 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
 	"github.com/AkashRajpurohit/git-sync/pkg/config"
-	"github.com/AkashRajpurohit/git-sync/pkg/helpers"
 	"github.com/AkashRajpurohit/git-sync/pkg/issues"
 	"github.com/AkashRajpurohit/git-sync/pkg/logger"
-	gitSync "github.com/AkashRajpurohit/git-sync/pkg/sync"
 	"github.com/AkashRajpurohit/git-sync/pkg/token"
 	gh "github.com/google/go-github/v82/github"
 	"golang.org/x/oauth2"
@@ -32,109 +32,113 @@ func (c *GitHubClient) GetTokenManager() *token.Manager {
 }
 
 func (c *GitHubClient) createClient() *gh.Client {
-	ctx := context.Background()
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: c.tokenManager.GetNextToken()},
 	)
-	tc := oauth2.NewClient(ctx, ts)
+	tc := oauth2.NewClient(context.Background(), ts)
 	return gh.NewClient(tc)
 }
 
-func (c *GitHubClient) Sync(cfg config.Config) error {
-	repos, err := c.getRepos(cfg)
+func (c *GitHubClient) ListRepositories(ctx context.Context, cfg config.Config) ([]client.Repository, error) {
+	repos, err := c.listRepos(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	gitSync.LogRepoCount(len(repos), cfg.Platform)
+	targetRepos, err := c.listTargetRepos(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	repos = append(repos, targetRepos...)
+
+	allRepos := make([]client.Repository, 0, len(repos))
+	for _, repo := range repos {
+		allRepos = append(allRepos, toRepository(repo, ""))
+	}
 
-	gitSync.SyncWithConcurrency(cfg, repos, func(repo *gh.Repository) {
-		owner := repo.GetOwner().GetLogin()
-		repoName := repo.GetName()
-		gitSync.CloneOrUpdateRepo(owner, repoName, cfg)
-		if cfg.IncludeWiki && repo.GetHasWiki() {
-			gitSync.SyncWiki(owner, repoName, cfg)
+	for _, ns := range c.namespaces(cfg) {
+		logger.Debugf("Fetching list of %s repositories ⏳", ns.name)
+		nsRepos, err := ns.list(ctx)
+		if err != nil {
+			return nil, err
 		}
-		if cfg.IncludeIssues && repo.GetHasIssues() {
-			since, hasPrevSync := issues.ReadLastSyncTime(cfg.BackupDir, owner, repoName)
-			allIssues, err := c.fetchIssues(owner, repoName, since, hasPrevSync)
-			if err != nil {
-				logger.Errorf("Failed to fetch issues for %s/%s: %v", owner, repoName, err)
-			} else {
-				gitSync.SyncIssues(owner, repoName, allIssues, cfg)
-			}
+		for _, repo := range nsRepos {
+			allRepos = append(allRepos, toRepository(repo, ns.dir))
 		}
-	})
+	}
 
-	gitSync.LogSyncSummary(&cfg)
-	return nil
+	return allRepos, nil
 }
 
-func (c *GitHubClient) getRepos(cfg config.Config) ([]*gh.Repository, error) {
-	logger.Debug("Fetching list of repositories ⏳")
-	ctx := context.Background()
-	client := c.createClient()
-	opt := &gh.RepositoryListByAuthenticatedUserOptions{
-		ListOptions: gh.ListOptions{PerPage: 100},
+// ForkOf looks the parent of a fork up, since listings do not include it
+func (c *GitHubClient) ForkOf(ctx context.Context, repo client.Repository) (string, error) {
+	ghRepo, _, err := c.createClient().Repositories.Get(ctx, repo.Owner, repo.Name)
+	if err != nil {
+		return "", statusError(err)
 	}
+	return ghRepo.GetParent().GetFullName(), nil
+}
 
-	var allRepos []*gh.Repository
-	for {
-		repos, resp, err := client.Repositories.ListByAuthenticatedUser(ctx, opt)
-		if err != nil {
-			logger.Debugf("Error with current token, trying next token: %v", err)
-			client = c.createClient()
-			continue
-		}
-
-		var reposToInclude []*gh.Repository
-		for _, repo := range repos {
-			repoName := repo.GetName()
-			isOrganizationRepo := repo.Owner.GetType() == "Organization"
-			orgName := repo.Owner.GetLogin()
-
-			if len(cfg.IncludeOrgs) > 0 {
-				if isOrganizationRepo && helpers.IsIncludedInList(cfg.IncludeOrgs, orgName) {
-					logger.Debug("[include_orgs] Repo included: ", repoName)
-					reposToInclude = append(reposToInclude, repo)
-				}
+func (c *GitHubClient) FetchIssues(ctx context.Context, repo client.Repository, since time.Time, incremental bool) ([]issues.Issue, error) {
+	return c.fetchIssues(ctx, repo.Owner, repo.Name, since, incremental)
+}
 
-				continue
-			}
+// listRepos returns every repository of the authenticated user before any filtering
+func (c *GitHubClient) listRepos(ctx context.Context) ([]*gh.Repository, error) {
+	logger.Debug("Fetching list of repositories ⏳")
+	return listPaged(ctx, c, func(ctx context.Context, client *gh.Client, opt gh.ListOptions) ([]*gh.Repository, *gh.Response, error) {
+		return client.Repositories.ListByAuthenticatedUser(ctx, &gh.RepositoryListByAuthenticatedUserOptions{ListOptions: opt})
+	})
+}
 
-			if len(cfg.ExcludeOrgs) > 0 {
-				if isOrganizationRepo && helpers.IsIncludedInList(cfg.ExcludeOrgs, orgName) {
-					logger.Debug("[exclude_orgs] Repo excluded: ", repoName)
-					continue
-				}
-			}
+// listTargetRepos returns the repositories of the configured users and orgs
+func (c *GitHubClient) listTargetRepos(ctx context.Context, cfg config.Config) ([]*gh.Repository, error) {
+	var targetRepos []*gh.Repository
 
-			if len(cfg.IncludeRepos) > 0 {
-				if helpers.IsIncludedInList(cfg.IncludeRepos, repoName) {
-					logger.Debug("[include_repos] Repo included: ", repoName)
-					reposToInclude = append(reposToInclude, repo)
-				}
+	for _, user := range cfg.Users {
+		logger.Debugf("Fetching list of repositories for user %s ⏳", user)
+		userRepos, err := listPaged(ctx, c, func(ctx context.Context, client *gh.Client, opt gh.ListOptions) ([]*gh.Repository, *gh.Response, error) {
+			return client.Repositories.ListByUser(ctx, user, &gh.RepositoryListByUserOptions{Type: "owner", ListOptions: opt})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories for %s: %w", user, err)
+		}
+		targetRepos = append(targetRepos, userRepos...)
+	}
 
-				continue
-			}
+	for _, org := range cfg.Orgs {
+		logger.Debugf("Fetching list of repositories for org %s ⏳", org)
+		orgRepos, err := listPaged(ctx, c, func(ctx context.Context, client *gh.Client, opt gh.ListOptions) ([]*gh.Repository, *gh.Response, error) {
+			return client.Repositories.ListByOrg(ctx, org, &gh.RepositoryListByOrgOptions{Type: "all", ListOptions: opt})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories for %s: %w", org, err)
+		}
+		targetRepos = append(targetRepos, orgRepos...)
+	}
 
-			if len(cfg.ExcludeRepos) > 0 {
-				if helpers.IsIncludedInList(cfg.ExcludeRepos, repoName) {
-					logger.Debug("[exclude_repos] Repo excluded: ", repoName)
-					continue
-				}
-			}
+	return targetRepos, nil
+}
 
-			if !cfg.IncludeForks && repo.GetFork() {
-				logger.Debug("[include_forks] Repo excluded: ", repoName)
-				continue
-			}
+// listPaged walks every page of a listing. Each page is a request of its own
+// that tries every token before giving up, see client.WithTokens.
+func listPaged[T any](ctx context.Context, c *GitHubClient, list func(context.Context, *gh.Client, gh.ListOptions) ([]T, *gh.Response, error)) ([]T, error) {
+	opt := gh.ListOptions{PerPage: 100}
 
-			logger.Debug("Repo included: ", repoName)
-			reposToInclude = append(reposToInclude, repo)
+	var all []T
+	for {
+		var items []T
+		var resp *gh.Response
+		err := client.WithTokens(ctx, c.tokenManager, func(ctx context.Context) error {
+			var err error
+			items, resp, err = list(ctx, c.createClient(), opt)
+			return statusError(err)
+		})
+		if err != nil {
+			return nil, err
 		}
 
-		allRepos = append(allRepos, reposToInclude...)
+		all = append(all, items...)
 		if resp.NextPage == 0 {
 			break
 		}
@@ -143,12 +147,39 @@ func (c *GitHubClient) getRepos(cfg config.Config) ([]*gh.Repository, error) {
 		opt.Page = resp.NextPage
 	}
 
-	return allRepos, nil
+	return all, nil
 }
 
-func (c *GitHubClient) fetchIssues(owner, repo string, since time.Time, incremental bool) ([]issues.Issue, error) {
+func toRepository(repo *gh.Repository, namespace string) client.Repository {
+	visibility := repo.GetVisibility()
+	if visibility == "" && repo.Private != nil {
+		visibility = "public"
+		if repo.GetPrivate() {
+			visibility = "private"
+		}
+	}
+
+	return client.Repository{
+		ID:         strconv.FormatInt(repo.GetID(), 10),
+		Owner:      repo.GetOwner().GetLogin(),
+		OwnerKind:  ownerKind(repo.GetOwner().GetType()),
+		Name:       repo.GetName(),
+		HasWiki:    repo.GetHasWiki(),
+		HasIssues:  repo.GetHasIssues(),
+		Fork:       repo.GetFork(),
+		ForkOf:     repo.GetParent().GetFullName(),
+		Archived:   repo.GetArchived(),
+		Visibility: visibility,
+		Topics:     repo.Topics,
+		Language:   repo.GetLanguage(),
+		PushedAt:   repo.GetPushedAt().Time,
+		SizeKB:     int64(repo.GetSize()),
+		Namespace:  namespace,
+	}
+}
+
+func (c *GitHubClient) fetchIssues(ctx context.Context, owner, repo string, since time.Time, incremental bool) ([]issues.Issue, error) {
 	repoFullName := fmt.Sprintf("%s/%s", owner, repo)
-	ctx := context.Background()
 	client := c.createClient()
 
 	opt := &gh.IssueListByRepoOptions{
@@ -167,7 +198,7 @@ func (c *GitHubClient) fetchIssues(owner, repo string, since time.Time, incremen
 	for {
 		ghIssues, resp, err := client.Issues.ListByRepo(ctx, owner, repo, opt)
 		if err != nil {
-			return nil, err
+			return nil, statusError(err)
 		}
 
 		for _, ghIssue := range ghIssues {
@@ -338,3 +369,40 @@ func convertGitHubIssue(ghIssue *gh.Issue) issues.Issue {
 
 	return issue
 }
+
+// statusError converts the API errors of go-github into a client.StatusError,
+// so rate limits are waited out and missing repositories are not retried
+func statusError(err error) error {
+	var rateLimitErr *gh.RateLimitError
+	var abuseErr *gh.AbuseRateLimitError
+	var responseErr *gh.ErrorResponse
+
+	switch {
+	case errors.As(err, &rateLimitErr) && rateLimitErr.Response != nil:
+		return &client.StatusError{
+			StatusCode: rateLimitErr.Response.StatusCode,
+			RetryAfter: max(time.Until(rateLimitErr.Rate.Reset.Time), time.Second),
+			Err:        err,
+		}
+	case errors.As(err, &abuseErr) && abuseErr.Response != nil:
+		retryAfter := time.Minute
+		if abuseErr.RetryAfter != nil {
+			retryAfter = *abuseErr.RetryAfter
+		}
+		return &client.StatusError{StatusCode: abuseErr.Response.StatusCode, RetryAfter: retryAfter, Err: err}
+	case errors.As(err, &responseErr) && responseErr.Response != nil:
+		return client.NewStatusError(responseErr.Response, err)
+	}
+	return err
+}
+
+// ownerKind returns the kind of the owner type GitHub reports
+func ownerKind(ownerType string) client.OwnerKind {
+	switch ownerType {
+	case "User":
+		return client.OwnerUser
+	case "Organization":
+		return client.OwnerOrg
+	}
+	return client.OwnerUnknown
+}
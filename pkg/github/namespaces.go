@@ -0,0 +1,50 @@
+package github
+
+import (
+	"context"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	gh "github.com/google/go-github/v82/github"
+)
+
+// namespace is a set of repositories the user does not own, such as starred
+// or watched ones, that is backed up in its own directory inside BackupDir.
+// The directory names start with an underscore, which GitHub does not allow
+// in user or organization names, so they never clash with an owner directory.
+type namespace struct {
+	name string
+	dir  string
+	list func(context.Context) ([]*gh.Repository, error)
+}
+
+func (c *GitHubClient) namespaces(cfg config.Config) []namespace {
+	var namespaces []namespace
+	if cfg.IncludeStarred {
+		namespaces = append(namespaces, namespace{name: "starred", dir: "_starred", list: c.listStarred})
+	}
+	if cfg.IncludeWatched {
+		namespaces = append(namespaces, namespace{name: "watched", dir: "_watched", list: c.listWatched})
+	}
+	return namespaces
+}
+
+func (c *GitHubClient) listStarred(ctx context.Context) ([]*gh.Repository, error) {
+	starred, err := listPaged(ctx, c, func(ctx context.Context, client *gh.Client, opt gh.ListOptions) ([]*gh.StarredRepository, *gh.Response, error) {
+		return client.Activity.ListStarred(ctx, "", &gh.ActivityListStarredOptions{ListOptions: opt})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	allRepos := make([]*gh.Repository, 0, len(starred))
+	for _, s := range starred {
+		allRepos = append(allRepos, s.GetRepository())
+	}
+	return allRepos, nil
+}
+
+func (c *GitHubClient) listWatched(ctx context.Context) ([]*gh.Repository, error) {
+	return listPaged(ctx, c, func(ctx context.Context, client *gh.Client, opt gh.ListOptions) ([]*gh.Repository, *gh.Response, error) {
+		return client.Activity.ListWatched(ctx, "", &opt)
+	})
+}
@@ -1,18 +1,28 @@
 package gitlab
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
 	"github.com/AkashRajpurohit/git-sync/pkg/config"
-	"github.com/AkashRajpurohit/git-sync/pkg/helpers"
 	"github.com/AkashRajpurohit/git-sync/pkg/issues"
 	"github.com/AkashRajpurohit/git-sync/pkg/logger"
-	gitSync "github.com/AkashRajpurohit/git-sync/pkg/sync"
 	"github.com/AkashRajpurohit/git-sync/pkg/token"
 	gl "github.com/xanzy/go-gitlab"
 )
 
+var accessLevels = map[string]gl.AccessLevelValue{
+	"guest":      gl.GuestPermissions,
+	"reporter":   gl.ReporterPermissions,
+	"developer":  gl.DeveloperPermissions,
+	"maintainer": gl.MaintainerPermissions,
+	"owner":      gl.OwnerPermissions,
+}
+
 type GitlabClient struct {
 	tokenManager *token.Manager
 	serverConfig config.Server
@@ -38,40 +48,32 @@ func (c *GitlabClient) createClient() (*gl.Client, error) {
 	return client, nil
 }
 
-func (c *GitlabClient) Sync(cfg config.Config) error {
-	projects, err := c.getProjects(cfg)
+func (c *GitlabClient) ListRepositories(ctx context.Context, cfg config.Config) ([]client.Repository, error) {
+	projects, err := c.listProjects(ctx, cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	gitSync.LogRepoCount(len(projects), cfg.Platform)
-
-	gitSync.SyncWithConcurrency(cfg, projects, func(project *gl.Project) {
-		gitSync.CloneOrUpdateRepo(project.Namespace.FullPath, project.Path, cfg)
-		if cfg.IncludeWiki && project.WikiEnabled {
-			gitSync.SyncWiki(project.Namespace.FullPath, project.Path, cfg)
-		}
-		if cfg.IncludeIssues && project.IssuesEnabled {
-			since, hasPrevSync := issues.ReadLastSyncTime(cfg.BackupDir, project.Namespace.FullPath, project.Path)
-			allIssues, err := c.fetchIssues(project.ID, since, hasPrevSync)
-			if err != nil {
-				logger.Errorf("Failed to fetch issues for %s/%s: %v", project.Namespace.FullPath, project.Path, err)
-			} else {
-				gitSync.SyncIssues(project.Namespace.FullPath, project.Path, allIssues, cfg)
-			}
-		}
-	})
+	repos := make([]client.Repository, 0, len(projects))
+	for _, project := range projects {
+		repos = append(repos, toRepository(project))
+	}
 
-	gitSync.LogSyncSummary(&cfg)
-	return nil
+	return repos, nil
 }
 
-func (c *GitlabClient) getProjects(cfg config.Config) ([]*gl.Project, error) {
-	client, err := c.createClient()
+func (c *GitlabClient) FetchIssues(ctx context.Context, repo client.Repository, since time.Time, incremental bool) ([]issues.Issue, error) {
+	projectID, err := strconv.Atoi(repo.ID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid project id %q: %v", repo.ID, err)
 	}
+	return c.fetchIssues(ctx, projectID, since, incremental)
+}
 
+// listProjects returns every project owned by the authenticated user, or that
+// they are a member of when gitlab.min_access_level is set, plus those of the
+// configured users and groups, before any filtering
+func (c *GitlabClient) listProjects(ctx context.Context, cfg config.Config) ([]*gl.Project, error) {
 	requestOpts := &gl.ListProjectsOptions{
 		ListOptions: gl.ListOptions{
 			OrderBy:    "id",
@@ -79,21 +81,32 @@ func (c *GitlabClient) getProjects(cfg config.Config) ([]*gl.Project, error) {
 			PerPage:    100,
 			Sort:       "asc",
 		},
-		Owned: &[]bool{true}[0],
+		Statistics: withStatistics(cfg),
 	}
 
-	options := []gl.RequestOptionFunc{}
+	// Without an access level only projects owned by the user are listed
+	if level, ok := accessLevels[cfg.GitLab.MinAccessLevel]; ok {
+		requestOpts.Membership = &[]bool{true}[0]
+		requestOpts.MinAccessLevel = gl.AccessLevel(level)
+	} else {
+		requestOpts.Owned = &[]bool{true}[0]
+	}
+
+	var options []gl.RequestOptionFunc
 	var projects []*gl.Project
 	for {
-		pageResults, response, err := client.Projects.ListProjects(requestOpts, options...)
-
-		if err != nil {
-			logger.Debugf("Error with current token, trying next token: %v", err)
-			client, err = c.createClient()
+		var pageResults []*gl.Project
+		var response *gl.Response
+		err := client.WithTokens(ctx, c.tokenManager, func(ctx context.Context) error {
+			glClient, err := c.createClient()
 			if err != nil {
-				return nil, err
+				return err
 			}
-			continue
+			pageResults, response, err = glClient.Projects.ListProjects(requestOpts, append(options, gl.WithContext(ctx))...)
+			return statusError(err)
+		})
+		if err != nil {
+			return nil, err
 		}
 
 		projects = append(projects, pageResults...)
@@ -107,58 +120,162 @@ func (c *GitlabClient) getProjects(cfg config.Config) ([]*gl.Project, error) {
 		}
 	}
 
-	var projectsToInclude []*gl.Project
-	for _, project := range projects {
-		projectName := project.Path
-		isFork := project.ForkedFromProject != nil
-		isGroupProject := project.Namespace.Kind == "group"
-		groupName := project.Namespace.FullPath
-
-		if len(cfg.IncludeOrgs) > 0 {
-			if isGroupProject && helpers.IsIncludedInList(cfg.IncludeOrgs, groupName) {
-				logger.Debug("[include_groups] Project included: ", projectName)
-				projectsToInclude = append(projectsToInclude, project)
-			}
+	targetProjects, err := c.listTargetProjects(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(projects, targetProjects...), nil
+}
+
+// listTargetProjects returns the projects of the configured users and groups
+func (c *GitlabClient) listTargetProjects(ctx context.Context, cfg config.Config) ([]*gl.Project, error) {
+	var targetProjects []*gl.Project
 
-			continue
+	for _, user := range cfg.Users {
+		logger.Debugf("Fetching list of projects for user %s ⏳", user)
+		userProjects, err := c.listPaged(ctx, user, func(ctx context.Context, client *gl.Client, opt gl.ListOptions) ([]*gl.Project, *gl.Response, error) {
+			return client.Projects.ListUserProjects(user, &gl.ListProjectsOptions{ListOptions: opt, Statistics: withStatistics(cfg)}, gl.WithContext(ctx))
+		})
+		if err != nil {
+			return nil, err
 		}
+		targetProjects = append(targetProjects, userProjects...)
+	}
 
-		if len(cfg.ExcludeOrgs) > 0 {
-			if isGroupProject && helpers.IsIncludedInList(cfg.ExcludeOrgs, groupName) {
-				logger.Debug("[exclude_groups] Project excluded: ", projectName)
-				continue
-			}
+	// Groups listed in orgs are always backed up with all their subgroups
+	for _, group := range cfg.Orgs {
+		groupProjects, err := c.listGroupProjects(ctx, cfg, group, true)
+		if err != nil {
+			return nil, err
 		}
+		targetProjects = append(targetProjects, groupProjects...)
+	}
 
-		if len(cfg.IncludeRepos) > 0 {
-			if helpers.IsIncludedInList(cfg.IncludeRepos, projectName) {
-				logger.Debug("[include_projects] Project included: ", projectName)
-				projectsToInclude = append(projectsToInclude, project)
-			}
+	for _, group := range cfg.GitLab.Groups {
+		groupProjects, err := c.listGroupProjects(ctx, cfg, group, cfg.GitLab.IncludeSubgroups)
+		if err != nil {
+			return nil, err
+		}
+		targetProjects = append(targetProjects, groupProjects...)
+	}
 
-			continue
+	return targetProjects, nil
+}
+
+// listGroupProjects returns the projects of group. The group listing cannot
+// include statistics, so when they are needed the projects are fetched one by
+// one with them.
+func (c *GitlabClient) listGroupProjects(ctx context.Context, cfg config.Config, group string, includeSubgroups bool) ([]*gl.Project, error) {
+	logger.Debugf("Fetching list of projects for group %s ⏳", group)
+	projects, err := c.listPaged(ctx, group, func(ctx context.Context, client *gl.Client, opt gl.ListOptions) ([]*gl.Project, *gl.Response, error) {
+		return client.Groups.ListGroupProjects(group, &gl.ListGroupProjectsOptions{
+			ListOptions:      opt,
+			IncludeSubGroups: &includeSubgroups,
+			WithShared:       &cfg.GitLab.IncludeShared,
+		}, gl.WithContext(ctx))
+	})
+	if err != nil || withStatistics(cfg) == nil {
+		return projects, err
+	}
+
+	for i, project := range projects {
+		err := client.WithTokens(ctx, c.tokenManager, func(ctx context.Context) error {
+			glClient, err := c.createClient()
+			if err != nil {
+				return err
+			}
+			projects[i], _, err = glClient.Projects.GetProject(project.ID, &gl.GetProjectOptions{Statistics: withStatistics(cfg)}, gl.WithContext(ctx))
+			return statusError(err)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the statistics of %s: %w", project.PathWithNamespace, err)
 		}
+	}
+	return projects, nil
+}
 
-		if len(cfg.ExcludeRepos) > 0 {
-			if helpers.IsIncludedInList(cfg.ExcludeRepos, projectName) {
-				logger.Debug("[exclude_projects] Project excluded: ", projectName)
-				continue
+// listPaged walks every page of a project listing. Each page is a request of
+// its own that tries every token before giving up, see client.WithTokens.
+func (c *GitlabClient) listPaged(ctx context.Context, target string, list func(context.Context, *gl.Client, gl.ListOptions) ([]*gl.Project, *gl.Response, error)) ([]*gl.Project, error) {
+	opt := gl.ListOptions{PerPage: 100}
+
+	var allProjects []*gl.Project
+	for {
+		var projects []*gl.Project
+		var resp *gl.Response
+		err := client.WithTokens(ctx, c.tokenManager, func(ctx context.Context) error {
+			glClient, err := c.createClient()
+			if err != nil {
+				return err
 			}
+			projects, resp, err = list(ctx, glClient, opt)
+			return statusError(err)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list projects for %s: %w", target, err)
 		}
 
-		if !cfg.IncludeForks && isFork {
-			logger.Debug("[include_forks] Fork excluded: ", projectName)
-			continue
+		allProjects = append(allProjects, projects...)
+		if resp.NextPage == 0 {
+			break
 		}
+		opt.Page = resp.NextPage
+	}
+
+	return allProjects, nil
+}
+
+// withStatistics requests repository statistics only when a size filter or
+// max_repo_size needs them, since computing them is an extra cost on the server
+func withStatistics(cfg config.Config) *bool {
+	if cfg.Filters.MinSizeMB > 0 || cfg.Filters.MaxSizeMB > 0 || cfg.MaxRepoSize > 0 {
+		return &[]bool{true}[0]
+	}
+	return nil
+}
+
+// ownerKind returns the kind of the namespace kind GitLab reports
+func ownerKind(namespaceKind string) client.OwnerKind {
+	switch namespaceKind {
+	case "user":
+		return client.OwnerUser
+	case "group":
+		return client.OwnerOrg
+	}
+	return client.OwnerUnknown
+}
 
-		logger.Debug("Project included: ", projectName)
-		projectsToInclude = append(projectsToInclude, project)
+func toRepository(project *gl.Project) client.Repository {
+	repo := client.Repository{
+		ID:         strconv.Itoa(project.ID),
+		Owner:      project.Namespace.FullPath,
+		OwnerKind:  ownerKind(project.Namespace.Kind),
+		Name:       project.Path,
+		HasWiki:    project.WikiEnabled,
+		HasIssues:  project.IssuesEnabled,
+		Fork:       project.ForkedFromProject != nil,
+		Archived:   project.Archived,
+		Visibility: string(project.Visibility),
+		Topics:     project.Topics,
 	}
 
-	return projectsToInclude, nil
+	if project.ForkedFromProject != nil {
+		repo.ForkOf = project.ForkedFromProject.PathWithNamespace
+	}
+
+	if project.LastActivityAt != nil {
+		repo.PushedAt = *project.LastActivityAt
+	}
+
+	if project.Statistics != nil {
+		repo.SizeKB = project.Statistics.RepositorySize / 1024
+	}
+
+	return repo
 }
 
-func (c *GitlabClient) fetchIssues(projectID int, since time.Time, incremental bool) ([]issues.Issue, error) {
+func (c *GitlabClient) fetchIssues(ctx context.Context, projectID int, since time.Time, incremental bool) ([]issues.Issue, error) {
 	client, err := c.createClient()
 	if err != nil {
 		return nil, err
@@ -181,16 +298,16 @@ func (c *GitlabClient) fetchIssues(projectID int, since time.Time, incremental b
 
 	var allIssues []issues.Issue
 	for {
-		glIssues, resp, err := client.Issues.ListProjectIssues(projectID, opt)
+		glIssues, resp, err := client.Issues.ListProjectIssues(projectID, opt, gl.WithContext(ctx))
 		if err != nil {
-			return nil, err
+			return nil, statusError(err)
 		}
 
 		for _, glIssue := range glIssues {
 			issue := convertGitLabIssue(glIssue)
 
 			logger.Debugf("Fetching notes for issue #%d in project %d", glIssue.IID, projectID)
-			notes, err := fetchIssueNotes(client, projectID, glIssue.IID)
+			notes, err := fetchIssueNotes(ctx, client, projectID, glIssue.IID)
 			if err != nil {
 				logger.Warnf("Failed to fetch notes for issue #%d: %v", glIssue.IID, err)
 			} else {
@@ -210,7 +327,7 @@ func (c *GitlabClient) fetchIssues(projectID int, since time.Time, incremental b
 	return allIssues, nil
 }
 
-func fetchIssueNotes(client *gl.Client, projectID, issueIID int) ([]issues.Comment, error) {
+func fetchIssueNotes(ctx context.Context, client *gl.Client, projectID, issueIID int) ([]issues.Comment, error) {
 	opt := &gl.ListIssueNotesOptions{
 		ListOptions: gl.ListOptions{
 			PerPage: 100,
@@ -219,7 +336,7 @@ func fetchIssueNotes(client *gl.Client, projectID, issueIID int) ([]issues.Comme
 
 	var allComments []issues.Comment
 	for {
-		notes, resp, err := client.Notes.ListIssueNotes(projectID, issueIID, opt)
+		notes, resp, err := client.Notes.ListIssueNotes(projectID, issueIID, opt, gl.WithContext(ctx))
 		if err != nil {
 			return nil, err
 		}
@@ -279,3 +396,13 @@ func convertGitLabIssue(glIssue *gl.Issue) issues.Issue {
 
 	return issue
 }
+
+// statusError converts the API errors of go-gitlab into a client.StatusError,
+// so rate limits are waited out and missing projects are not retried
+func statusError(err error) error {
+	var responseErr *gl.ErrorResponse
+	if errors.As(err, &responseErr) && responseErr.Response != nil {
+		return client.NewStatusError(responseErr.Response, err)
+	}
+	return err
+}
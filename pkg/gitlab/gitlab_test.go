@@ -0,0 +1,101 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+// project is a project as the fake GitLab API returns it, with statistics
+// only when they are asked for
+func project(id int, namespace, name string, r *http.Request) map[string]any {
+	p := map[string]any{
+		"id":                  id,
+		"path":                name,
+		"path_with_namespace": namespace + "/" + name,
+		"namespace":           map[string]any{"full_path": namespace, "kind": "group"},
+	}
+	if r.URL.Query().Get("statistics") == "true" {
+		p["statistics"] = map[string]any{"repository_size": 2048 * 1024}
+	}
+	return p
+}
+
+func TestListRepositoriesStatistics(t *testing.T) {
+	logger.InitLogger("fatal")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body any
+		switch path := strings.TrimPrefix(r.URL.Path, "/api/v4"); path {
+		case "/projects":
+			body = []any{project(1, "alice", "owned", r)}
+		case "/users/bob/projects":
+			body = []any{project(2, "bob", "lib", r)}
+		case "/groups/acme/projects":
+			// Group listings never include statistics
+			p := project(3, "acme", "web", r)
+			delete(p, "statistics")
+			body = []any{p}
+		case "/projects/3":
+			body = project(3, "acme", "web", r)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	c := NewGitlabClient(config.Server{Domain: serverURL.Host, Protocol: "http"}, []string{"token"})
+
+	tests := []struct {
+		name   string
+		cfg    config.Config
+		wantKB int64
+	}{
+		{
+			name:   "max_repo_size needs statistics",
+			cfg:    config.Config{MaxRepoSize: 1},
+			wantKB: 2048,
+		},
+		{
+			name:   "size filters need statistics",
+			cfg:    config.Config{Filters: config.FilterConfig{MaxSizeMB: 1}},
+			wantKB: 2048,
+		},
+		{
+			name:   "no size limit",
+			wantKB: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Users = []string{"bob"}
+			cfg.Orgs = []string{"acme"}
+
+			repos, err := c.ListRepositories(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("ListRepositories() error = %v", err)
+			}
+			if len(repos) != 3 {
+				t.Fatalf("Expected 3 repositories, got %d: %+v", len(repos), repos)
+			}
+			for _, repo := range repos {
+				if repo.SizeKB != tt.wantKB {
+					t.Errorf("Size of %s = %d KB, want %d KB", repo.FullName(), repo.SizeKB, tt.wantKB)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package gogs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/token"
+)
+
+// GogsClient talks to the Gogs REST API, which predates the Gitea fork and
+// lacks most of the endpoints the Forgejo SDK relies on.
+type GogsClient struct {
+	tokenManager *token.Manager
+	serverConfig config.Server
+	httpClient   *http.Client
+}
+
+type repository struct {
+	ID    int64 `json:"id"`
+	Owner struct {
+		UserName string `json:"username"`
+	} `json:"owner"`
+	Name    string `json:"name"`
+	Private bool   `json:"private"`
+	Fork    bool   `json:"fork"`
+	Parent  *struct {
+		FullName string `json:"full_name"`
+	} `json:"parent"`
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
+	HasWiki   bool      `json:"has_wiki"`
+}
+
+func NewGogsClient(serverConfig config.Server, tokens []string) *GogsClient {
+	return &GogsClient{
+		tokenManager: token.NewManager(tokens),
+		serverConfig: serverConfig,
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (c *GogsClient) GetTokenManager() *token.Manager {
+	return c.tokenManager
+}
+
+func (c *GogsClient) ListRepositories(ctx context.Context, cfg config.Config) ([]client.Repository, error) {
+	repos, err := c.listRepos(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	allRepos := make([]client.Repository, 0, len(repos))
+	for _, repo := range repos {
+		allRepos = append(allRepos, toRepository(repo))
+	}
+
+	return allRepos, nil
+}
+
+// listRepos returns the repositories of the authenticated user followed by
+// those of the additional users and orgs
+func (c *GogsClient) listRepos(ctx context.Context, cfg config.Config) ([]*repository, error) {
+	paths := []string{"/api/v1/user/repos"}
+	for _, user := range cfg.Users {
+		paths = append(paths, fmt.Sprintf("/api/v1/users/%s/repos", url.PathEscape(user)))
+	}
+	for _, org := range cfg.Orgs {
+		paths = append(paths, fmt.Sprintf("/api/v1/orgs/%s/repos", url.PathEscape(org)))
+	}
+
+	var allRepos []*repository
+	for _, path := range paths {
+		logger.Debugf("Fetching list of repositories from %s ⏳", path)
+
+		// Gogs returns every repository at once instead of paginating
+		var repos []*repository
+		if err := c.get(ctx, path, &repos); err != nil {
+			return nil, err
+		}
+
+		allRepos = append(allRepos, repos...)
+	}
+
+	return allRepos, nil
+}
+
+// get requests an API resource and decodes it into out
+func (c *GogsClient) get(ctx context.Context, path string, out interface{}) error {
+	reqURL := fmt.Sprintf("%s://%s%s", c.serverConfig.Protocol, strings.TrimSuffix(c.serverConfig.Domain, "/"), path)
+
+	return client.WithTokens(ctx, c.tokenManager, func(ctx context.Context) error {
+		return c.getOnce(ctx, reqURL, out)
+	})
+}
+
+func (c *GogsClient) getOnce(ctx context.Context, reqURL string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "token "+c.tokenManager.GetNextToken())
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %v", req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	if err := client.CheckResponse(resp, req.URL.Path); err != nil {
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %v", req.URL.Path, err)
+	}
+
+	return nil
+}
+
+func toRepository(repo *repository) client.Repository {
+	visibility := "public"
+	if repo.Private {
+		visibility = "private"
+	}
+
+	r := client.Repository{
+		ID:         strconv.FormatInt(repo.ID, 10),
+		Owner:      repo.Owner.UserName,
+		Name:       repo.Name,
+		HasWiki:    repo.HasWiki,
+		Fork:       repo.Fork,
+		Visibility: visibility,
+		PushedAt:   repo.UpdatedAt,
+		SizeKB:     repo.Size / 1024,
+	}
+	if repo.Parent != nil {
+		r.ForkOf = repo.Parent.FullName
+	}
+	return r
+}
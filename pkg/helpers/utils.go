@@ -1,13 +1,23 @@
 package helpers
 
-import "path/filepath"
+import "fmt"
 
-func IsIncludedInList(list []string, value string) bool {
-	for _, includeValue := range list {
-		match, err := filepath.Match(includeValue, value)
-		if err == nil && match {
-			return true
-		}
+// FormatSize returns a size in bytes in the largest binary unit it reaches,
+// like 1.5 MiB
+func FormatSize(bytes int64) string {
+	sign := ""
+	if bytes < 0 {
+		sign, bytes = "-", -bytes
 	}
-	return false
+	if bytes < 1024 {
+		return fmt.Sprintf("%s%d B", sign, bytes)
+	}
+
+	size := float64(bytes) / 1024
+	unit := 0
+	for size >= 1024 && unit < 4 {
+		size /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%s%.1f %ciB", sign, size, "KMGTP"[unit])
 }
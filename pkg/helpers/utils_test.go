@@ -4,23 +4,24 @@ import (
 	"testing"
 )
 
-func TestIsIncludedInListFunction(t *testing.T) {
+func TestFormatSize(t *testing.T) {
 	tests := []struct {
-		list  []string
-		value string
-		want  bool
+		bytes int64
+		want  string
 	}{
-		{[]string{"foo", "bar"}, "baz", false},
-		{[]string{"foo", "bar"}, "foo", true},
-		{[]string{"foo", "ba*"}, "bar", true}, // partial match should return true
-		{[]string{}, "anything", false},
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 * 1024 * 1024, "5.0 MiB"},
+		{3 * 1024 * 1024 * 1024, "3.0 GiB"},
+		{-2048, "-2.0 KiB"},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.value, func(t *testing.T) {
-			got := IsIncludedInList(tt.list, tt.value)
-			if got != tt.want {
-				t.Errorf("IsIncludedInList(%v, %q) = %t; want %t", tt.list, tt.value, got, tt.want)
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatSize(tt.bytes); got != tt.want {
+				t.Errorf("FormatSize(%d) = %q; want %q", tt.bytes, got, tt.want)
 			}
 		})
 	}
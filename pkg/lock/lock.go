@@ -0,0 +1,72 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileName is the name of the lock file created in the backup directory
+const FileName = ".git-sync.lock"
+
+// ErrLocked is returned by Acquire when another process holds the lock
+var ErrLocked = errors.New("backup directory is locked by another git-sync process")
+
+// Lock is an exclusive lock on a backup directory. The operating system
+// releases it when the process exits, so a crashed run never leaves a stale
+// lock behind.
+type Lock struct {
+	file *os.File
+}
+
+// Acquire locks dir for this process, failing with ErrLocked instead of
+// waiting when another process holds the lock.
+func Acquire(dir string) (*Lock, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, FileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, fmt.Errorf("%w (pid %s, lock file %s)", ErrLocked, holder(path), path)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %v", path, err)
+	}
+
+	// Record who holds the lock for the error above. The lock itself does
+	// not depend on the contents.
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &Lock{file: file}, nil
+}
+
+// Release unlocks the directory. The lock file is left in place, removing it
+// would let two processes lock different files of the same name.
+func (l *Lock) Release() error {
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// holder returns the pid recorded in the lock file at path
+func holder(path string) string {
+	data, err := os.ReadFile(path)
+	if pid := strings.TrimSpace(string(data)); err == nil && pid != "" {
+		return pid
+	}
+	return "unknown"
+}
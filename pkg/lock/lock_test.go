@@ -0,0 +1,55 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAcquire(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Expected to acquire the lock, got %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("Expected the lock file to exist, got %v", err)
+	}
+	if pid := strings.TrimSpace(string(data)); pid != strconv.Itoa(os.Getpid()) {
+		t.Errorf("Expected the lock file to hold pid %d, got %q", os.Getpid(), pid)
+	}
+
+	if _, err := Acquire(dir); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked while the lock is held, got %v", err)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Expected to release the lock, got %v", err)
+	}
+
+	second, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Expected to acquire the released lock, got %v", err)
+	}
+	second.Release()
+}
+
+func TestAcquireCreatesDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups")
+
+	l, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Expected to acquire the lock, got %v", err)
+	}
+	defer l.Release()
+
+	if _, err := os.Stat(filepath.Join(dir, FileName)); err != nil {
+		t.Errorf("Expected the lock file to be created, got %v", err)
+	}
+}
@@ -11,6 +11,16 @@ var (
 )
 
 func InitLogger(logLevel string) {
+	initLogger(logLevel, "stdout")
+}
+
+// InitStderrLogger is like InitLogger but writes to stderr, keeping stdout free
+// for command output that is meant to be piped.
+func InitStderrLogger(logLevel string) {
+	initLogger(logLevel, "stderr")
+}
+
+func initLogger(logLevel, outputPath string) {
 	var level zapcore.Level
 	switch logLevel {
 	case "debug":
@@ -48,7 +58,7 @@ func InitLogger(logLevel string) {
 			EncodeDuration: zapcore.StringDurationEncoder,
 			EncodeCaller:   zapcore.ShortCallerEncoder,
 		},
-		OutputPaths:      []string{"stdout"},
+		OutputPaths:      []string{outputPath},
 		ErrorOutputPaths: []string{"stderr"},
 		InitialFields:    map[string]interface{}{"app": "git-sync"},
 	}
@@ -101,3 +111,47 @@ func Errorf(format string, args ...interface{}) {
 func Fatalf(format string, args ...interface{}) {
 	sugar.Fatalf(format, args...)
 }
+
+// Logger adds a fixed set of fields to every entry, such as the source a
+// sync is running for
+type Logger struct {
+	sugar *zap.SugaredLogger
+}
+
+// With returns a Logger that adds the given key value pairs to every entry.
+// It must be called after the logger is initialized.
+func With(keysAndValues ...interface{}) *Logger {
+	return &Logger{sugar: sugar.With(keysAndValues...)}
+}
+
+func (l *Logger) Debug(args ...interface{}) {
+	l.sugar.Debug(args...)
+}
+
+func (l *Logger) Info(args ...interface{}) {
+	l.sugar.Info(args...)
+}
+
+func (l *Logger) Warn(args ...interface{}) {
+	l.sugar.Warn(args...)
+}
+
+func (l *Logger) Error(args ...interface{}) {
+	l.sugar.Error(args...)
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.sugar.Debugf(format, args...)
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.sugar.Infof(format, args...)
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.sugar.Warnf(format, args...)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.sugar.Errorf(format, args...)
+}
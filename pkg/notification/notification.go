@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/helpers"
 	"github.com/AkashRajpurohit/git-sync/pkg/logger"
 )
 
@@ -19,10 +20,29 @@ type SyncSummary struct {
 	WikisFailed   []string
 	IssuesSuccess int
 	IssuesFailed  []string
+	TimedOut      []string
+	// SkippedTooLarge lists the new repositories above max_repo_size that
+	// were not cloned, SkippedLowSpace those left out by min_free_space
+	SkippedTooLarge []string
+	SkippedLowSpace []string
+	DiskUsage       int64               // bytes the backups of the source take, 0 when not measured
+	Maintenance     *MaintenanceSummary // the maintenance run after the sync, nil when there was none
+}
+
+// MaintenanceSummary sums up a maintenance run
+type MaintenanceSummary struct {
+	Maintained int
+	Failed     []string
+	Reclaimed  int64 // bytes, negative when the repositories grew
 }
 
 func (s *SyncSummary) HasFailures() bool {
-	return len(s.ReposFailed) > 0 || len(s.WikisFailed) > 0 || len(s.IssuesFailed) > 0
+	return len(s.ReposFailed) > 0 || len(s.WikisFailed) > 0 || len(s.IssuesFailed) > 0 || len(s.SkippedLowSpace) > 0 ||
+		s.Maintenance != nil && s.Maintenance.HasFailures()
+}
+
+func (s *MaintenanceSummary) HasFailures() bool {
+	return len(s.Failed) > 0
 }
 
 func (s *SyncSummary) FormatMessage() string {
@@ -52,20 +72,72 @@ func (s *SyncSummary) FormatMessage() string {
 		}
 	}
 
+	if len(s.TimedOut) > 0 {
+		sb.WriteString(fmt.Sprintf("⏱️ Timed out or stalled: %d\n", len(s.TimedOut)))
+		for _, name := range s.TimedOut {
+			sb.WriteString(fmt.Sprintf("- %s\n", name))
+		}
+	}
+
+	if len(s.SkippedTooLarge) > 0 {
+		sb.WriteString(fmt.Sprintf("📦 Not cloned, larger than max_repo_size: %d\n", len(s.SkippedTooLarge)))
+		for _, name := range s.SkippedTooLarge {
+			sb.WriteString(fmt.Sprintf("- %s\n", name))
+		}
+	}
+
+	if len(s.SkippedLowSpace) > 0 {
+		sb.WriteString(fmt.Sprintf("🚫 Not cloned, free disk space below min_free_space: %d\n", len(s.SkippedLowSpace)))
+		for _, name := range s.SkippedLowSpace {
+			sb.WriteString(fmt.Sprintf("- %s\n", name))
+		}
+	}
+
+	if s.DiskUsage > 0 {
+		sb.WriteString(fmt.Sprintf("💾 Disk usage: %s\n", helpers.FormatSize(s.DiskUsage)))
+	}
+
+	if s.Maintenance != nil {
+		sb.WriteString(s.Maintenance.FormatMessage())
+	}
+
+	return sb.String()
+}
+
+func (s *MaintenanceSummary) FormatMessage() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("🧹 Maintenance: %d repositories maintained, %s reclaimed\n", s.Maintained, helpers.FormatSize(s.Reclaimed)))
+	if len(s.Failed) > 0 {
+		sb.WriteString(fmt.Sprintf("❌ Failed maintenance: %d\n", len(s.Failed)))
+		for _, repo := range s.Failed {
+			sb.WriteString(fmt.Sprintf("- %s\n", repo))
+		}
+	}
+
 	return sb.String()
 }
 
 func NotifyAll(cfg *config.NotificationConfig, summary *SyncSummary) error {
+	return notify(cfg, summary.HasFailures(), summary.FormatMessage())
+}
+
+// NotifyMaintenance sends the summary of a maintenance run that did not
+// follow a sync
+func NotifyMaintenance(cfg *config.NotificationConfig, summary *MaintenanceSummary) error {
+	return notify(cfg, summary.HasFailures(), summary.FormatMessage())
+}
+
+func notify(cfg *config.NotificationConfig, hasFailures bool, message string) error {
 	if !cfg.Enabled {
 		return nil
 	}
 
-	if cfg.OnlyFailures && !summary.HasFailures() {
+	if cfg.OnlyFailures && !hasFailures {
 		return nil
 	}
 
 	title := "Git-Sync Operation Summary"
-	message := summary.FormatMessage()
 
 	var errors []string
 
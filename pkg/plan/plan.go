@@ -0,0 +1,305 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/filter"
+	"github.com/AkashRajpurohit/git-sync/pkg/issues"
+	gitSync "github.com/AkashRajpurohit/git-sync/pkg/sync"
+)
+
+type Kind string
+
+const (
+	KindRepo   Kind = "repo"
+	KindWiki   Kind = "wiki"
+	KindIssues Kind = "issues"
+)
+
+type Action string
+
+const (
+	ActionClone  Action = "clone"
+	ActionUpdate Action = "update"
+	ActionSkip   Action = "skip"
+	ActionOrphan Action = "orphan"
+)
+
+// Entry describes what a sync would do with a single repository, wiki or issue set.
+type Entry struct {
+	Kind   Kind   `json:"kind"`
+	Name   string `json:"name"`
+	Action Action `json:"action"`
+	Reason string `json:"reason,omitempty"`
+	Path   string `json:"path"`
+}
+
+// Plan collects the entries for a sync without touching the backup directory.
+type Plan struct {
+	cfg     config.Config
+	Entries []Entry `json:"entries"`
+}
+
+func New(cfg config.Config) *Plan {
+	return &Plan{cfg: cfg}
+}
+
+// Build discovers the repositories of a client and records what a sync would
+// do with them, with the overrides matching them applied.
+func Build(ctx context.Context, cfg config.Config, c client.Client) (*Plan, error) {
+	overrides, err := filter.CompileOverrides(cfg.Overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := gitSync.Discover(ctx, cfg, c)
+	if err != nil {
+		return nil, err
+	}
+
+	p := New(cfg)
+	var namespaces []string
+	namespacePlans := make(map[string]*Plan)
+	for _, candidate := range candidates {
+		target := p
+		if candidate.Namespace != "" {
+			if namespacePlans[candidate.Namespace] == nil {
+				namespacePlans[candidate.Namespace] = New(gitSync.NamespaceConfig(cfg, candidate.Namespace))
+				namespaces = append(namespaces, candidate.Namespace)
+			}
+			target = namespacePlans[candidate.Namespace]
+		}
+
+		repoPlan := New(overrides.Apply(target.cfg, candidate.Repository))
+		if candidate.Container {
+			if candidate.Decision.Included {
+				repoPlan.AddWiki(candidate.Owner, candidate.Name, candidate.HasWiki)
+				repoPlan.AddIssues(candidate.Owner, candidate.Name, candidate.HasIssues)
+			}
+		} else {
+			decision := candidate.Decision
+			if decision.Included && tooLarge(repoPlan.cfg, candidate.Repository) {
+				decision = filter.Decision{Rule: "max_repo_size"}
+			}
+			repoPlan.AddRepository(candidate.Owner, candidate.Name, decision, candidate.HasWiki, candidate.HasIssues)
+		}
+		target.Merge(repoPlan)
+	}
+
+	for _, namespace := range namespaces {
+		p.Merge(namespacePlans[namespace])
+	}
+
+	return p, nil
+}
+
+// AddRepository records the actions for a discovered repository. hasWiki and
+// hasIssues report whether the platform has them enabled for the repository.
+func (p *Plan) AddRepository(repoOwner, repoName string, decision filter.Decision, hasWiki, hasIssues bool) {
+	repoFullName := fmt.Sprintf("%s/%s", repoOwner, repoName)
+	repoPath := gitSync.RepoPath(repoOwner, repoName, p.cfg)
+
+	if !decision.Included {
+		p.Entries = append(p.Entries, Entry{
+			Kind:   KindRepo,
+			Name:   repoFullName,
+			Action: ActionSkip,
+			Reason: fmt.Sprintf("excluded by %s", decision.Rule),
+			Path:   repoPath,
+		})
+		return
+	}
+
+	p.Entries = append(p.Entries, Entry{
+		Kind:   KindRepo,
+		Name:   repoFullName,
+		Action: existingAction(repoPath),
+		Reason: includedReason(decision),
+		Path:   repoPath,
+	})
+
+	p.AddWiki(repoOwner, repoName, hasWiki)
+	p.AddIssues(repoOwner, repoName, hasIssues)
+}
+
+// AddWiki records the action for a wiki stored at the wiki path of
+// repoOwner/repoName. It is a no-op unless include_wiki is set.
+func (p *Plan) AddWiki(repoOwner, repoName string, hasWiki bool) {
+	if !p.cfg.IncludeWiki {
+		return
+	}
+
+	wikiPath := gitSync.WikiPath(repoOwner, repoName, p.cfg)
+	entry := Entry{Kind: KindWiki, Name: fmt.Sprintf("%s/%s", repoOwner, repoName), Path: wikiPath}
+	if hasWiki {
+		entry.Action = existingAction(wikiPath)
+	} else {
+		entry.Action = ActionSkip
+		entry.Reason = "wiki disabled for repository"
+	}
+	p.Entries = append(p.Entries, entry)
+}
+
+// AddIssues records the action for the issues of repoOwner/repoName. It is a
+// no-op unless include_issues is set.
+func (p *Plan) AddIssues(repoOwner, repoName string, hasIssues bool) {
+	if !p.cfg.IncludeIssues {
+		return
+	}
+
+	issuesPath := filepath.Join(p.cfg.BackupDir, repoOwner, repoName, "issues")
+	entry := Entry{Kind: KindIssues, Name: fmt.Sprintf("%s/%s", repoOwner, repoName), Path: issuesPath}
+	if !hasIssues {
+		entry.Action = ActionSkip
+		entry.Reason = "issues disabled or not supported for repository"
+	} else if since, ok := issues.ReadLastSyncTime(p.cfg.BackupDir, repoOwner, repoName); ok {
+		entry.Action = ActionUpdate
+		entry.Reason = fmt.Sprintf("issues updated since %s", since.Format(time.RFC3339))
+	} else {
+		entry.Action = ActionClone
+		entry.Reason = "full fetch"
+	}
+	p.Entries = append(p.Entries, entry)
+}
+
+// Merge appends the entries of other to the plan.
+func (p *Plan) Merge(other *Plan) {
+	if other == nil {
+		return
+	}
+	p.Entries = append(p.Entries, other.Entries...)
+}
+
+// FindOrphans walks the backup directory and records repositories and wikis
+// that exist on disk but were not discovered by any client.
+func (p *Plan) FindOrphans() error {
+	known := make(map[string]bool, len(p.Entries))
+	for _, entry := range p.Entries {
+		known[filepath.Clean(entry.Path)] = true
+	}
+
+	err := filepath.WalkDir(p.cfg.BackupDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == p.cfg.BackupDir {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if !d.IsDir() || path == p.cfg.BackupDir {
+			return nil
+		}
+
+		name := d.Name()
+		if name == "issues" || gitSync.IsTempDir(name) || gitSync.IsPoolDir(name) || gitSync.IsCheckout(path) {
+			return filepath.SkipDir
+		}
+		if !strings.HasSuffix(name, ".git") {
+			return nil
+		}
+
+		if !known[filepath.Clean(path)] {
+			kind := KindRepo
+			if strings.HasSuffix(name, ".wiki.git") {
+				kind = KindWiki
+			}
+			rel, _ := filepath.Rel(p.cfg.BackupDir, filepath.Dir(path))
+			p.Entries = append(p.Entries, Entry{
+				Kind:   kind,
+				Name:   filepath.ToSlash(rel),
+				Action: ActionOrphan,
+				Reason: "not found on any configured source",
+				Path:   path,
+			})
+		}
+		return filepath.SkipDir
+	})
+
+	return err
+}
+
+// Counts returns the number of entries per action.
+func (p *Plan) Counts() map[Action]int {
+	counts := make(map[Action]int)
+	for _, entry := range p.Entries {
+		counts[entry.Action]++
+	}
+	return counts
+}
+
+func (p *Plan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Entries []Entry        `json:"entries"`
+		Summary map[Action]int `json:"summary"`
+	}{
+		Entries: p.sortedEntries(),
+		Summary: p.Counts(),
+	})
+}
+
+func (p *Plan) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tACTION\tREPOSITORY\tREASON")
+	for _, entry := range p.sortedEntries() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.Kind, entry.Action, entry.Name, entry.Reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	counts := p.Counts()
+	_, err := fmt.Fprintf(w, "\n%d to clone, %d to update, %d skipped, %d orphaned\n",
+		counts[ActionClone], counts[ActionUpdate], counts[ActionSkip], counts[ActionOrphan])
+	return err
+}
+
+func (p *Plan) sortedEntries() []Entry {
+	entries := make([]Entry, len(p.Entries))
+	copy(entries, p.Entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// tooLarge reports whether a sync would skip repo for exceeding
+// max_repo_size, which only applies to repositories that are not backed up
+// yet
+func tooLarge(cfg config.Config, repo client.Repository) bool {
+	return cfg.OversizeAction != "shallow" && gitSync.ExceedsMaxRepoSize(cfg, repo) &&
+		existingAction(gitSync.RepoPath(repo.Owner, repo.Name, cfg)) == ActionClone
+}
+
+// existingAction returns whether the repository at path is cloned or updated.
+// A directory that is not a valid repository is removed and cloned again, as
+// the sync does.
+func existingAction(path string) Action {
+	_, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		return ActionClone
+	case err == nil && !gitSync.IsRepository(path):
+		return ActionClone
+	}
+	return ActionUpdate
+}
+
+func includedReason(decision filter.Decision) string {
+	if decision.Rule != "" {
+		return fmt.Sprintf("matched %s", decision.Rule)
+	}
+	return ""
+}
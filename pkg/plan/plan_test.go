@@ -0,0 +1,286 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/filter"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	gitSync "github.com/AkashRajpurohit/git-sync/pkg/sync"
+	"github.com/AkashRajpurohit/git-sync/pkg/token"
+)
+
+// createRepo lays out a bare repository at path, as far as telling it apart
+// from other directories goes
+func createRepo(t *testing.T, path string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Join(path, "objects"), os.ModePerm); err != nil {
+		t.Fatalf("Failed to create repo dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "HEAD"), []byte("ref: refs/heads/main\n"), 0o644); err != nil {
+		t.Fatalf("Failed to create repo dir: %v", err)
+	}
+}
+
+func TestAddRepository(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := config.Config{BackupDir: tmpDir, IncludeWiki: true, IncludeIssues: true}
+
+	createRepo(t, filepath.Join(tmpDir, "alice", "existing", "existing.git"))
+	// Left behind by an interrupted clone, it is cloned again
+	if err := os.MkdirAll(filepath.Join(tmpDir, "alice", "broken", "broken.git"), os.ModePerm); err != nil {
+		t.Fatalf("Failed to create repo dir: %v", err)
+	}
+
+	p := New(cfg)
+	p.AddRepository("alice", "existing", filter.Decision{Included: true}, false, true)
+	p.AddRepository("alice", "new", filter.Decision{Included: true}, true, false)
+	p.AddRepository("alice", "fork", filter.Decision{Rule: "include_forks"}, true, true)
+	p.AddRepository("alice", "broken", filter.Decision{Included: true}, false, false)
+
+	tests := []struct {
+		kind   Kind
+		name   string
+		action Action
+	}{
+		{KindRepo, "alice/existing", ActionUpdate},
+		{KindWiki, "alice/existing", ActionSkip},
+		{KindIssues, "alice/existing", ActionClone},
+		{KindRepo, "alice/new", ActionClone},
+		{KindWiki, "alice/new", ActionClone},
+		{KindIssues, "alice/new", ActionSkip},
+		{KindRepo, "alice/fork", ActionSkip},
+		{KindRepo, "alice/broken", ActionClone},
+		{KindWiki, "alice/broken", ActionSkip},
+		{KindIssues, "alice/broken", ActionSkip},
+	}
+
+	if len(p.Entries) != len(tests) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(tests), len(p.Entries), p.Entries)
+	}
+
+	for i, tt := range tests {
+		entry := p.Entries[i]
+		if entry.Kind != tt.kind || entry.Name != tt.name || entry.Action != tt.action {
+			t.Errorf("Entry %d = %s %s %s, want %s %s %s", i, entry.Kind, entry.Name, entry.Action, tt.kind, tt.name, tt.action)
+		}
+	}
+
+	if p.Entries[6].Reason != "excluded by include_forks" {
+		t.Errorf("Skip reason = %q, want %q", p.Entries[6].Reason, "excluded by include_forks")
+	}
+}
+
+func TestFindOrphans(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := config.Config{BackupDir: tmpDir}
+
+	for _, dir := range []string{
+		filepath.Join("alice", "kept", "kept.git"),
+		filepath.Join("alice", "gone", "gone.git"),
+		filepath.Join("alice", "gone", "gone.wiki.git"),
+		filepath.Join("alice", "gone", "issues", "json"),
+		filepath.Join(".git-sync-pools", "github", "alice", "kept.git"),
+		filepath.Join("alice", "kept", ".checkout", "vendored.git"),
+	} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), os.ModePerm); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	// The checkout of kept is a worktree and its contents are not backups
+	checkout := gitSync.CheckoutPath("alice", "kept", cfg)
+	if err := os.WriteFile(filepath.Join(checkout, ".git"), []byte("gitdir: ../kept.git/worktrees/-checkout\n"), 0o644); err != nil {
+		t.Fatalf("Failed to create the checkout: %v", err)
+	}
+
+	p := New(cfg)
+	p.AddRepository("alice", "kept", filter.Decision{Included: true}, false, false)
+
+	if err := p.FindOrphans(); err != nil {
+		t.Fatalf("FindOrphans failed: %v", err)
+	}
+
+	counts := p.Counts()
+	if counts[ActionOrphan] != 2 {
+		t.Errorf("Expected 2 orphans, got %d: %+v", counts[ActionOrphan], p.Entries)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	var decoded struct {
+		Entries []Entry        `json:"entries"`
+		Summary map[Action]int `json:"summary"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode plan JSON: %v", err)
+	}
+	if decoded.Summary[ActionOrphan] != 2 || len(decoded.Entries) != 3 {
+		t.Errorf("Unexpected JSON plan: %s", buf.String())
+	}
+}
+
+func TestFindOrphansMissingBackupDir(t *testing.T) {
+	cfg := config.Config{BackupDir: filepath.Join(t.TempDir(), "missing")}
+
+	p := New(cfg)
+	if err := p.FindOrphans(); err != nil {
+		t.Fatalf("FindOrphans failed: %v", err)
+	}
+	if len(p.Entries) != 0 {
+		t.Errorf("Expected no entries, got %+v", p.Entries)
+	}
+}
+
+type fakeClient struct {
+	repos []client.Repository
+}
+
+func (c *fakeClient) ListRepositories(ctx context.Context, cfg config.Config) ([]client.Repository, error) {
+	return c.repos, nil
+}
+
+func (c *fakeClient) GetTokenManager() *token.Manager {
+	return nil
+}
+
+func TestBuild(t *testing.T) {
+	logger.InitLogger("fatal")
+
+	tmpDir := t.TempDir()
+	cfg := config.Config{BackupDir: tmpDir, IncludeWiki: true, StarredMaxSizeMB: 1}
+
+	c := &fakeClient{repos: []client.Repository{
+		{Owner: "alice", Name: "app", HasWiki: true, HasIssues: true},
+		{Owner: "alice", Name: "fork", Fork: true},
+		{Owner: "alice", Name: "app", Namespace: "_starred"},
+		{Owner: "bob", Name: "lib", Namespace: "_starred"},
+		{Owner: "bob", Name: "huge", Namespace: "_starred", SizeKB: 4096},
+		{Owner: "acme", Name: "web", HasWiki: true, Container: true},
+		{Owner: "acme/web", Name: "site"},
+		{Owner: "acme", Name: "empty", HasWiki: true, Container: true},
+	}}
+
+	p, err := Build(context.Background(), cfg, c)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	tests := []struct {
+		kind   Kind
+		name   string
+		action Action
+		path   string
+	}{
+		{KindRepo, "alice/app", ActionClone, filepath.Join(tmpDir, "alice", "app", "app.git")},
+		{KindWiki, "alice/app", ActionClone, filepath.Join(tmpDir, "alice", "app", "app.wiki.git")},
+		{KindRepo, "alice/fork", ActionSkip, filepath.Join(tmpDir, "alice", "fork", "fork.git")},
+		{KindWiki, "acme/web", ActionClone, filepath.Join(tmpDir, "acme", "web", "web.wiki.git")},
+		{KindRepo, "acme/web/site", ActionClone, filepath.Join(tmpDir, "acme", "web", "site", "site.git")},
+		{KindWiki, "acme/web/site", ActionSkip, filepath.Join(tmpDir, "acme", "web", "site", "site.wiki.git")},
+		{KindRepo, "bob/lib", ActionClone, filepath.Join(tmpDir, "_starred", "bob", "lib", "lib.git")},
+		{KindWiki, "bob/lib", ActionSkip, filepath.Join(tmpDir, "_starred", "bob", "lib", "lib.wiki.git")},
+		{KindRepo, "bob/huge", ActionSkip, filepath.Join(tmpDir, "_starred", "bob", "huge", "huge.git")},
+	}
+
+	if len(p.Entries) != len(tests) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(tests), len(p.Entries), p.Entries)
+	}
+
+	for i, tt := range tests {
+		entry := p.Entries[i]
+		if entry.Kind != tt.kind || entry.Name != tt.name || entry.Action != tt.action || entry.Path != tt.path {
+			t.Errorf("Entry %d = %s %s %s %s, want %s %s %s %s", i, entry.Kind, entry.Name, entry.Action, entry.Path, tt.kind, tt.name, tt.action, tt.path)
+		}
+	}
+}
+
+func TestBuildContainers(t *testing.T) {
+	logger.InitLogger("fatal")
+
+	c := &fakeClient{repos: []client.Repository{
+		{Owner: "acme", OwnerKind: client.OwnerOrg, Name: "web", HasWiki: true, Container: true},
+		{Owner: "acme/web", OwnerKind: client.OwnerOrg, Name: "site"},
+		{Owner: "acme/web", OwnerKind: client.OwnerOrg, Name: "docs"},
+	}}
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		wantWiki bool
+	}{
+		{"Include repos", config.Config{IncludeRepos: []string{"site"}}, true},
+		{"Include orgs", config.Config{IncludeOrgs: []string{"acme/web"}}, true},
+		{"No included repository", config.Config{IncludeRepos: []string{"other"}}, false},
+		{"Exclude orgs", config.Config{ExcludeOrgs: []string{"acme"}}, false},
+		{"Exclude repos", config.Config{ExcludeRepos: []string{"acme/web"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.BackupDir = t.TempDir()
+			tt.cfg.IncludeWiki = true
+
+			p, err := Build(context.Background(), tt.cfg, c)
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			gotWiki := false
+			for _, entry := range p.Entries {
+				if entry.Kind == KindWiki && entry.Name == "acme/web" {
+					gotWiki = entry.Action == ActionClone
+				}
+			}
+			if gotWiki != tt.wantWiki {
+				t.Errorf("Wiki of acme/web cloned = %v, want %v: %+v", gotWiki, tt.wantWiki, p.Entries)
+			}
+		})
+	}
+}
+
+func TestBuildMaxRepoSize(t *testing.T) {
+	logger.InitLogger("fatal")
+
+	tmpDir := t.TempDir()
+	createRepo(t, filepath.Join(tmpDir, "alice", "kept", "kept.git"))
+	c := &fakeClient{repos: []client.Repository{
+		{Owner: "alice", Name: "small", SizeKB: 512},
+		{Owner: "alice", Name: "huge", SizeKB: 4096},
+		{Owner: "alice", Name: "kept", SizeKB: 4096},
+	}}
+
+	tests := []struct {
+		action string
+		want   []Action
+	}{
+		{"skip", []Action{ActionClone, ActionSkip, ActionUpdate}},
+		{"shallow", []Action{ActionClone, ActionClone, ActionUpdate}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			cfg := config.Config{BackupDir: tmpDir, MaxRepoSize: 1, OversizeAction: tt.action}
+			p, err := Build(context.Background(), cfg, c)
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			if len(p.Entries) != len(tt.want) {
+				t.Fatalf("Expected %d entries, got %+v", len(tt.want), p.Entries)
+			}
+			for i, want := range tt.want {
+				if p.Entries[i].Action != want {
+					t.Errorf("Entry %s = %s, want %s", p.Entries[i].Name, p.Entries[i].Action, want)
+				}
+			}
+		})
+	}
+}
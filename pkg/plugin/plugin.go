@@ -0,0 +1,217 @@
+// Package plugin implements the exec platform, which delegates repository
+// discovery to an external program so in-house git hosting can be backed up
+// without changes to git-sync.
+//
+// The program is invoked as `<exec.command> <exec.args...> <subcommand>`:
+//
+//	repos                 print a JSON array of repositories (see Repository)
+//	issues <owner> <name> print the issues of a repository in the issues.Issue
+//	                      JSON schema, one object after the other
+//
+// The issues subcommand is only invoked for repositories with has_issues set
+// and when include_issues is enabled. After a previous sync GIT_SYNC_SINCE
+// holds the RFC 3339 time of the most recently updated issue, so only newer
+// issues need to be printed.
+//
+// The program receives the configured username, the next token, and the
+// server protocol and domain as GIT_SYNC_USERNAME, GIT_SYNC_TOKEN,
+// GIT_SYNC_SERVER_PROTOCOL and GIT_SYNC_SERVER_DOMAIN. Anything it writes to
+// stderr is logged at debug level, and a non-zero exit status fails the sync.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/issues"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/token"
+)
+
+// Repository is a repository as printed by the repos subcommand. Only owner,
+// name and clone_url are required. Owner and name are used as directory names,
+// so they may neither contain path separators nor be "." or "..". http(s)
+// clone and wiki URLs without a password get the configured username and
+// token added as credentials.
+type Repository struct {
+	Owner      string    `json:"owner"`
+	Name       string    `json:"name"`
+	CloneURL   string    `json:"clone_url"`
+	WikiURL    string    `json:"wiki_url,omitempty"`
+	HasIssues  bool      `json:"has_issues,omitempty"`
+	Fork       bool      `json:"fork,omitempty"`
+	ForkOf     string    `json:"fork_of,omitempty"` // owner/name of the repository it was forked from
+	Archived   bool      `json:"archived,omitempty"`
+	Visibility string    `json:"visibility,omitempty"`
+	Topics     []string  `json:"topics,omitempty"`
+	Language   string    `json:"language,omitempty"`
+	PushedAt   time.Time `json:"pushed_at,omitempty"`
+	SizeKB     int64     `json:"size_kb,omitempty"`
+}
+
+type ExecClient struct {
+	tokenManager *token.Manager
+	cfg          config.Config
+}
+
+func NewExecClient(cfg config.Config) *ExecClient {
+	return &ExecClient{
+		tokenManager: token.NewManager(cfg.Tokens),
+		cfg:          cfg,
+	}
+}
+
+func (c *ExecClient) GetTokenManager() *token.Manager {
+	return c.tokenManager
+}
+
+func (c *ExecClient) ListRepositories(ctx context.Context, cfg config.Config) ([]client.Repository, error) {
+	repos, err := c.listRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allRepos := make([]client.Repository, 0, len(repos))
+	for _, repo := range repos {
+		allRepos = append(allRepos, toRepository(repo))
+	}
+
+	return allRepos, nil
+}
+
+func (c *ExecClient) listRepos(ctx context.Context) ([]Repository, error) {
+	logger.Debugf("Fetching list of repositories from %s ⏳", c.cfg.Exec.Command)
+
+	output, err := c.run(ctx, nil, "repos")
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []Repository
+	if err := json.Unmarshal(output, &repos); err != nil {
+		return nil, fmt.Errorf("failed to decode repositories printed by %s: %v", c.cfg.Exec.Command, err)
+	}
+
+	for i, repo := range repos {
+		if repo.Owner == "" || repo.Name == "" || repo.CloneURL == "" {
+			return nil, fmt.Errorf("repository %d printed by %s is missing owner, name or clone_url", i, c.cfg.Exec.Command)
+		}
+		if !pathSegment(repo.Owner) || !pathSegment(repo.Name) {
+			return nil, fmt.Errorf("repository %d printed by %s has an invalid owner %q or name %q", i, c.cfg.Exec.Command, repo.Owner, repo.Name)
+		}
+	}
+
+	return repos, nil
+}
+
+// pathSegment reports whether value can be used as a single directory name
+// inside the backup directory
+func pathSegment(value string) bool {
+	return value != "" && value != "." && value != ".." && !strings.ContainsAny(value, `/\`)
+}
+
+func (c *ExecClient) FetchIssues(ctx context.Context, repo client.Repository, since time.Time, incremental bool) ([]issues.Issue, error) {
+	var env []string
+	if incremental {
+		env = append(env, "GIT_SYNC_SINCE="+since.Format(time.RFC3339))
+		logger.Debugf("Incremental fetch for %s/%s (issues updated since %s)", repo.Owner, repo.Name, since.Format(time.RFC3339))
+	} else {
+		logger.Debugf("Full fetch for %s/%s ⏳", repo.Owner, repo.Name)
+	}
+
+	output, err := c.run(ctx, env, "issues", repo.Owner, repo.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeIssues(bytes.NewReader(output))
+}
+
+// decodeIssues reads a stream of issue objects, accepting both JSON lines and
+// a single JSON array
+func decodeIssues(r io.Reader) ([]issues.Issue, error) {
+	decoder := json.NewDecoder(r)
+	var allIssues []issues.Issue
+
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to decode issues: %v", err)
+		}
+
+		trimmed := bytes.TrimSpace(raw)
+		if len(trimmed) > 0 && trimmed[0] == '[' {
+			var batch []issues.Issue
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return nil, fmt.Errorf("failed to decode issues: %v", err)
+			}
+			allIssues = append(allIssues, batch...)
+			continue
+		}
+
+		var issue issues.Issue
+		if err := json.Unmarshal(raw, &issue); err != nil {
+			return nil, fmt.Errorf("failed to decode issue: %v", err)
+		}
+		allIssues = append(allIssues, issue)
+	}
+
+	return allIssues, nil
+}
+
+// run invokes the program with the subcommand and returns its stdout
+func (c *ExecClient) run(ctx context.Context, env []string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, c.cfg.Exec.Command, append(append([]string{}, c.cfg.Exec.Args...), args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_SYNC_USERNAME="+c.cfg.Username,
+		"GIT_SYNC_TOKEN="+c.tokenManager.GetNextToken(),
+		"GIT_SYNC_SERVER_PROTOCOL="+c.cfg.Server.Protocol,
+		"GIT_SYNC_SERVER_DOMAIN="+c.cfg.Server.Domain,
+	)
+	cmd.Env = append(cmd.Env, env...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if stderr.Len() > 0 {
+		logger.Debugf("Output of %s %s: %s", c.cfg.Exec.Command, args[0], stderr.String())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %v", c.cfg.Exec.Command, args[0], err)
+	}
+
+	return output, nil
+}
+
+func toRepository(repo Repository) client.Repository {
+	return client.Repository{
+		Owner:      repo.Owner,
+		Name:       repo.Name,
+		CloneURL:   repo.CloneURL,
+		WikiURL:    repo.WikiURL,
+		HasWiki:    repo.WikiURL != "",
+		HasIssues:  repo.HasIssues,
+		Fork:       repo.Fork,
+		ForkOf:     repo.ForkOf,
+		Archived:   repo.Archived,
+		Visibility: repo.Visibility,
+		Topics:     repo.Topics,
+		Language:   repo.Language,
+		PushedAt:   repo.PushedAt,
+		SizeKB:     repo.SizeKB,
+	}
+}
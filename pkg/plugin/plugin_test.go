@@ -0,0 +1,102 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+func TestDecodeIssues(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{
+			name:  "Empty output",
+			input: "",
+			want:  nil,
+		},
+		{
+			name:  "JSON lines",
+			input: "{\"number\": 1, \"title\": \"first\"}\n{\"number\": 2, \"title\": \"second\"}\n",
+			want:  []int{1, 2},
+		},
+		{
+			name:  "JSON array",
+			input: `[{"number": 3}, {"number": 4}]`,
+			want:  []int{3, 4},
+		},
+		{
+			name:    "Invalid JSON",
+			input:   `{"number": `,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeIssues(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeIssues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("decodeIssues() returned %d issues, want %d", len(got), len(tt.want))
+			}
+			for i, number := range tt.want {
+				if got[i].Number != number {
+					t.Errorf("Issue %d number = %d, want %d", i, got[i].Number, number)
+				}
+			}
+		})
+	}
+}
+
+func TestListReposRejectsUnsafePaths(t *testing.T) {
+	logger.InitLogger("fatal")
+
+	tests := []struct {
+		name    string
+		owner   string
+		repo    string
+		wantErr bool
+	}{
+		{name: "Valid", owner: "alice", repo: "app"},
+		{name: "Dots in name", owner: "alice", repo: "app.js"},
+		{name: "Empty owner", owner: "", repo: "app", wantErr: true},
+		{name: "Current directory", owner: ".", repo: "app", wantErr: true},
+		{name: "Parent directory", owner: "alice", repo: "..", wantErr: true},
+		{name: "Traversal", owner: "../..", repo: "app", wantErr: true},
+		{name: "Slash", owner: "alice", repo: "a/b", wantErr: true},
+		{name: "Backslash", owner: `a\b`, repo: "app", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := json.Marshal([]Repository{{Owner: tt.owner, Name: tt.repo, CloneURL: "https://example.com/repo.git"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := NewExecClient(config.Config{Exec: config.ExecConfig{
+				Command: "sh",
+				Args:    []string{"-c", `printf '%s' "$1"`, "plugin", string(output)},
+			}})
+
+			repos, err := c.listRepos(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listRepos() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "sh") {
+				t.Errorf("Error %q does not name the plugin", err)
+			}
+			if !tt.wantErr && len(repos) != 1 {
+				t.Errorf("listRepos() returned %d repositories, want 1", len(repos))
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+// Package queue orders the repositories of a sync and records which of them
+// are done, so a sync that is interrupted resumes where it stopped.
+//
+// The progress of every source is kept in a state file in the backup
+// directory. A sync that finishes marks its source finished. A sync that is
+// interrupted, be it by a shutdown or a crash, leaves it unfinished, and the
+// next sync of the source skips the repositories it completed, provided it
+// starts within ResumeWindow.
+//
+// The outcome of every repository is appended to a journal next to the state
+// file rather than rewriting it each time. The journal is folded into the
+// state file when a sync starts and when it finishes.
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/filter"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+// FileName is the name of the state file in the backup directory
+const FileName = ".git-sync-queue.json"
+
+// JournalName is the name of the journal in the backup directory
+const JournalName = ".git-sync-queue.journal"
+
+// ResumeWindow is how long an interrupted sync can be resumed. Repositories it
+// completed longer ago are synced again.
+const ResumeWindow = 24 * time.Hour
+
+// state is the content of the state file
+type state struct {
+	Sources map[string]*progress `json:"sources"`
+}
+
+// progress is the progress of the last sync of a source
+type progress struct {
+	StartedAt time.Time            `json:"started_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	Finished  bool                 `json:"finished"`
+	Completed map[string]time.Time `json:"completed"`
+	Failed    map[string]string    `json:"failed"` // the error of each repository that failed
+}
+
+// outcome is a line of the journal
+type outcome struct {
+	Source string    `json:"source"`
+	Key    string    `json:"key"`
+	At     time.Time `json:"at"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Queue is the progress of a single sync of a source
+type Queue struct {
+	path    string
+	journal string
+	source  string
+	now     func() time.Time
+
+	mu    sync.Mutex
+	state state
+
+	resumed        map[string]bool // completed by the interrupted sync being resumed
+	failedLastTime map[string]bool
+}
+
+// Key identifies a repository in the state file
+func Key(repo client.Repository) string {
+	return path.Join(repo.Namespace, repo.FullName())
+}
+
+// Open starts a sync of source, resuming the interrupted one if resume is set.
+// A missing or unreadable state file starts the sync from scratch.
+func Open(backupDir, source string, resume bool) *Queue {
+	q := &Queue{
+		path:           filepath.Join(backupDir, FileName),
+		journal:        filepath.Join(backupDir, JournalName),
+		source:         source,
+		now:            time.Now,
+		resumed:        make(map[string]bool),
+		failedLastTime: make(map[string]bool),
+	}
+	q.load()
+
+	last := q.state.Sources[source]
+	now := q.now()
+	if last != nil {
+		for key := range last.Failed {
+			q.failedLastTime[key] = true
+		}
+	}
+
+	if resume && last != nil && !last.Finished && now.Sub(last.UpdatedAt) < ResumeWindow {
+		for key := range last.Completed {
+			q.resumed[key] = true
+		}
+		logger.Infof("Resuming the %s sync started at %s, %d repositories are already done",
+			source, last.StartedAt.Format(time.RFC3339), len(q.resumed))
+		last.UpdatedAt = now
+		last.Failed = make(map[string]string)
+	} else {
+		q.state.Sources[source] = &progress{
+			StartedAt: now,
+			UpdatedAt: now,
+			Completed: make(map[string]time.Time),
+			Failed:    make(map[string]string),
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.save()
+	return q
+}
+
+// Resumed reports whether the interrupted sync being resumed already
+// completed repo
+func (q *Queue) Resumed(repo client.Repository) bool {
+	return q.resumed[Key(repo)]
+}
+
+// Done records the outcome of repo
+func (q *Queue) Done(repo client.Repository, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	o := outcome{Source: q.source, Key: Key(repo), At: q.now()}
+	if err != nil {
+		o.Error = err.Error()
+	}
+	q.state.apply(o)
+	q.appendJournal(o)
+}
+
+// apply records o in the progress of its source. Outcomes of sources that
+// finished since were folded into the state file already.
+func (s *state) apply(o outcome) {
+	p := s.Sources[o.Source]
+	if p == nil || p.Finished {
+		return
+	}
+
+	p.UpdatedAt = o.At
+	if o.Error != "" {
+		p.Failed[o.Key] = o.Error
+		delete(p.Completed, o.Key)
+	} else {
+		p.Completed[o.Key] = o.At
+		delete(p.Failed, o.Key)
+	}
+}
+
+// Finish marks the sync finished, so the next one starts from scratch
+func (q *Queue) Finish() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	p := q.state.Sources[q.source]
+	p.UpdatedAt = q.now()
+	p.Finished = true
+	// Only the failures are needed for the order of the next sync
+	p.Completed = make(map[string]time.Time)
+	q.save()
+}
+
+// Sort orders repos by the criteria of cfg.Order, applied in turn to
+// repositories that compare equal on the ones before. Repositories equal on
+// all of them keep the order they are given in. The priority order puts the
+// repositories matching the priority patterns of cfg first, along with those
+// prioritized reports true for when it is not nil.
+func (q *Queue) Sort(cfg config.QueueConfig, repos []client.Repository, prioritized func(client.Repository) bool) error {
+	priorityRepos, err := filter.Compile("queue.priority_repos", cfg.PriorityRepos)
+	if err != nil {
+		return err
+	}
+	priorityTopics, err := filter.Compile("queue.priority_topics", cfg.PriorityTopics)
+	if err != nil {
+		return err
+	}
+
+	isPriority := func(repo client.Repository) bool {
+		return priorityRepos.Match(repo.Name, repo.FullName()) || priorityTopics.Match(repo.Topics...) ||
+			prioritized != nil && prioritized(repo)
+	}
+
+	sort.SliceStable(repos, func(i, j int) bool {
+		a, b := repos[i], repos[j]
+		for _, order := range cfg.Order {
+			switch order {
+			case "priority":
+				if pa, pb := isPriority(a), isPriority(b); pa != pb {
+					return pa
+				}
+			case "failed":
+				if fa, fb := q.failedLastTime[Key(a)], q.failedLastTime[Key(b)]; fa != fb {
+					return fa
+				}
+			case "pushed":
+				if !a.PushedAt.Equal(b.PushedAt) {
+					return a.PushedAt.After(b.PushedAt)
+				}
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+func (q *Queue) load() {
+	q.state = state{Sources: make(map[string]*progress)}
+
+	data, err := os.ReadFile(q.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("Failed to read %s, starting from scratch: %v", q.path, err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &q.state); err != nil {
+		logger.Warnf("Failed to parse %s, starting from scratch: %v", q.path, err)
+		q.state = state{Sources: make(map[string]*progress)}
+		return
+	}
+	if q.state.Sources == nil {
+		q.state.Sources = make(map[string]*progress)
+	}
+	for _, p := range q.state.Sources {
+		if p.Completed == nil {
+			p.Completed = make(map[string]time.Time)
+		}
+		if p.Failed == nil {
+			p.Failed = make(map[string]string)
+		}
+	}
+	q.replayJournal()
+}
+
+// replayJournal applies the outcomes in the journal to the state. A line cut
+// short by a crash is skipped.
+func (q *Queue) replayJournal() {
+	f, err := os.Open(q.journal)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("Failed to read %s, the progress it holds is lost: %v", q.journal, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var o outcome
+		if err := json.Unmarshal(scanner.Bytes(), &o); err != nil {
+			continue
+		}
+		q.state.apply(o)
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Warnf("Failed to read %s, the progress it holds is lost: %v", q.journal, err)
+	}
+}
+
+// appendJournal appends o to the journal. The caller must hold q.mu.
+func (q *Queue) appendJournal(o outcome) {
+	line, err := json.Marshal(o)
+	if err != nil {
+		logger.Warnf("Failed to save the sync progress: %v", err)
+		return
+	}
+
+	f, err := os.OpenFile(q.journal, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Warnf("Failed to save the sync progress: %v", err)
+		return
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logger.Warnf("Failed to save the sync progress: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		logger.Warnf("Failed to save the sync progress: %v", err)
+	}
+}
+
+// save writes the state file, replacing it at once so a crash never leaves a
+// partial one behind, and drops the journal it includes. The caller must hold
+// q.mu.
+func (q *Queue) save() {
+	data, err := json.MarshalIndent(q.state, "", "  ")
+	if err != nil {
+		logger.Warnf("Failed to save the sync progress: %v", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.path), os.ModePerm); err != nil {
+		logger.Warnf("Failed to save the sync progress: %v", err)
+		return
+	}
+	tmpPath := q.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		logger.Warnf("Failed to save the sync progress: %v", err)
+		return
+	}
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		logger.Warnf("Failed to save the sync progress: %v", err)
+		return
+	}
+	if err := os.Remove(q.journal); err != nil && !os.IsNotExist(err) {
+		logger.Warnf("Failed to remove %s: %v", q.journal, err)
+	}
+}
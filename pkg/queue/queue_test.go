@@ -0,0 +1,216 @@
+package queue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+func init() {
+	logger.InitLogger("fatal")
+}
+
+func repo(name string) client.Repository {
+	return client.Repository{Owner: "owner", Name: name}
+}
+
+func TestResume(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := repo("a"), repo("b"), repo("c")
+
+	q := Open(dir, "github", true)
+	q.Done(a, nil)
+	q.Done(b, errors.New("clone failed"))
+
+	// The sync was interrupted before c, so the next one resumes
+	q = Open(dir, "github", true)
+	if !q.Resumed(a) {
+		t.Errorf("Expected the completed repository to be skipped when resuming")
+	}
+	if q.Resumed(b) || q.Resumed(c) {
+		t.Errorf("Expected failed and pending repositories to be synced when resuming")
+	}
+
+	q.Done(b, nil)
+	q.Done(c, nil)
+	q.Finish()
+
+	q = Open(dir, "github", true)
+	if q.Resumed(a) || q.Resumed(b) || q.Resumed(c) {
+		t.Errorf("Expected a finished sync not to be resumed")
+	}
+}
+
+func TestResumeDisabled(t *testing.T) {
+	dir := t.TempDir()
+
+	q := Open(dir, "github", true)
+	q.Done(repo("a"), nil)
+
+	if q := Open(dir, "github", false); q.Resumed(repo("a")) {
+		t.Errorf("Expected nothing to be resumed when resume is disabled")
+	}
+}
+
+func TestResumeSourcesSeparately(t *testing.T) {
+	dir := t.TempDir()
+
+	q := Open(dir, "github", true)
+	q.Done(repo("a"), nil)
+
+	if q := Open(dir, "raw", true); q.Resumed(repo("a")) {
+		t.Errorf("Expected the progress of another source not to be resumed")
+	}
+	if q := Open(dir, "github", true); !q.Resumed(repo("a")) {
+		t.Errorf("Expected the progress of the source to survive the sync of another one")
+	}
+}
+
+func TestResumeWindow(t *testing.T) {
+	dir := t.TempDir()
+
+	q := Open(dir, "github", true)
+	q.Done(repo("a"), nil)
+	q.now = func() time.Time { return time.Now().Add(-ResumeWindow - time.Hour) }
+	q.Done(repo("b"), nil)
+
+	if q := Open(dir, "github", true); q.Resumed(repo("a")) {
+		t.Errorf("Expected a sync interrupted longer than the resume window ago not to be resumed")
+	}
+}
+
+func TestCorruptStateFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	q := Open(dir, "github", true)
+	q.Done(repo("a"), nil)
+	if q := Open(dir, "github", true); !q.Resumed(repo("a")) {
+		t.Errorf("Expected a corrupt state file to be replaced")
+	}
+}
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, FileName)
+	journalPath := filepath.Join(dir, JournalName)
+
+	q := Open(dir, "github", true)
+	saved, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Done(repo("a"), nil)
+	q.Done(repo("b"), errors.New("clone failed"))
+
+	if data, err := os.ReadFile(statePath); err != nil || string(data) != string(saved) {
+		t.Errorf("Expected outcomes to be appended to the journal instead of rewriting the state file")
+	}
+
+	// A crash while appending leaves a line cut short
+	f, err := os.OpenFile(journalPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"source":"github","key":"owner/c"`)
+	f.Close()
+
+	q = Open(dir, "github", true)
+	if !q.Resumed(repo("a")) || q.Resumed(repo("b")) || q.Resumed(repo("c")) {
+		t.Errorf("Expected only the completed repository in the journal to be resumed")
+	}
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal to be folded into the state file when a sync starts")
+	}
+
+	q.Done(repo("b"), nil)
+	q.Finish()
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal to be folded into the state file when a sync finishes")
+	}
+	if q := Open(dir, "github", true); q.Resumed(repo("a")) || q.Resumed(repo("b")) {
+		t.Errorf("Expected a finished sync not to be resumed")
+	}
+}
+
+func TestSort(t *testing.T) {
+	now := time.Now()
+	repos := []client.Repository{
+		{Owner: "owner", Name: "old", PushedAt: now.Add(-48 * time.Hour)},
+		{Owner: "owner", Name: "recent", PushedAt: now},
+		{Owner: "owner", Name: "failing", PushedAt: now.Add(-72 * time.Hour)},
+		{Owner: "owner", Name: "important", PushedAt: now.Add(-96 * time.Hour)},
+		{Owner: "owner", Name: "tagged", PushedAt: now.Add(-24 * time.Hour), Topics: []string{"backup-first"}},
+	}
+
+	dir := t.TempDir()
+	q := Open(dir, "github", false)
+	q.Done(repos[2], errors.New("fetch failed"))
+	q.Finish()
+	q = Open(dir, "github", false)
+
+	tests := []struct {
+		name        string
+		order       []string
+		prioritized func(client.Repository) bool
+		want        []string
+	}{
+		{
+			name:  "API order",
+			order: nil,
+			want:  []string{"old", "recent", "failing", "important", "tagged"},
+		},
+		{
+			name:  "Recently pushed first",
+			order: []string{"pushed"},
+			want:  []string{"recent", "tagged", "old", "failing", "important"},
+		},
+		{
+			name:  "Failed first",
+			order: []string{"failed"},
+			want:  []string{"failing", "old", "recent", "important", "tagged"},
+		},
+		{
+			name:        "Prioritized by the caller",
+			order:       []string{"priority", "pushed"},
+			prioritized: func(repo client.Repository) bool { return repo.Name == "old" },
+			want:        []string{"tagged", "old", "important", "recent", "failing"},
+		},
+		{
+			name:  "Priority, then failed, then pushed",
+			order: []string{"priority", "failed", "pushed"},
+			want:  []string{"tagged", "important", "failing", "recent", "old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := append([]client.Repository(nil), repos...)
+			cfg := config.QueueConfig{
+				Order:          tt.order,
+				PriorityRepos:  []string{"important"},
+				PriorityTopics: []string{"backup-*"},
+			}
+			if err := q.Sort(cfg, sorted, tt.prioritized); err != nil {
+				t.Fatalf("Sort() error = %v", err)
+			}
+
+			var got []string
+			for _, r := range sorted {
+				got = append(got, r.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Sort() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
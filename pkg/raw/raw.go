@@ -1,11 +1,13 @@
 package raw
 
 import (
+	"context"
 	"path/filepath"
 	"strings"
 
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
 	"github.com/AkashRajpurohit/git-sync/pkg/config"
-	gitSync "github.com/AkashRajpurohit/git-sync/pkg/sync"
+	"github.com/AkashRajpurohit/git-sync/pkg/token"
 )
 
 type RawClient struct{}
@@ -28,18 +30,22 @@ func (c RawClient) extractRepoInfo(url string) (string, string) {
 	return parts[len(parts)-2], parts[len(parts)-1]
 }
 
-func (c RawClient) Sync(cfg config.Config) error {
-	if len(cfg.RawGitURLs) == 0 {
-		return nil
+func (c RawClient) ListRepositories(ctx context.Context, cfg config.Config) ([]client.Repository, error) {
+	repos := make([]client.Repository, 0, len(cfg.RawGitURLs))
+	for _, repoURL := range cfg.RawGitURLs {
+		owner, name := c.extractRepoInfo(repoURL)
+		repos = append(repos, client.Repository{
+			Owner:    owner,
+			Name:     name,
+			CloneURL: repoURL,
+			Raw:      true,
+		})
 	}
 
-	gitSync.LogRepoCount(len(cfg.RawGitURLs), "raw")
-
-	gitSync.SyncWithConcurrency(cfg, cfg.RawGitURLs, func(repoURL string) {
-		owner, name := c.extractRepoInfo(repoURL)
-		gitSync.CloneOrUpdateRawRepo(owner, name, repoURL, cfg)
-	})
+	return repos, nil
+}
 
-	gitSync.LogSyncSummary(&cfg)
+// GetTokenManager returns nil since raw repositories are cloned without tokens
+func (c RawClient) GetTokenManager() *token.Manager {
 	return nil
 }
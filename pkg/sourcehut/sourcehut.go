@@ -0,0 +1,232 @@
+package sourcehut
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/issues"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/token"
+)
+
+// SourcehutClient backs up git.sr.ht repositories through the GraphQL API.
+// Server.Domain is the git service, e.g. git.sr.ht, and the todo service used
+// for issues is derived from it by replacing the leading "git.".
+//
+// Sourcehut has no wikis and its trackers are not tied to repositories, so the
+// tickets of a tracker are stored as the issues of the repository sharing its
+// name.
+type SourcehutClient struct {
+	tokenManager *token.Manager
+	serverConfig config.Server
+	httpClient   *http.Client
+}
+
+type entity struct {
+	CanonicalName string `json:"canonicalName"`
+}
+
+// name returns the canonical name without the "~" prefix of users, which is
+// how owners are stored in the backup directory
+func (e entity) name() string {
+	return strings.TrimPrefix(e.CanonicalName, "~")
+}
+
+type repository struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Visibility string    `json:"visibility"` // PUBLIC, UNLISTED or PRIVATE
+	Updated    time.Time `json:"updated"`
+	Owner      entity    `json:"owner"`
+}
+
+type cursor[T any] struct {
+	Results []T     `json:"results"`
+	Cursor  *string `json:"cursor"`
+}
+
+const repositoriesQuery = `query repositories($username: String!, $cursor: Cursor) {
+  user(username: $username) {
+    repositories(cursor: $cursor) {
+      results { id name visibility updated owner { canonicalName } }
+      cursor
+    }
+  }
+}`
+
+func NewSourcehutClient(serverConfig config.Server, tokens []string) *SourcehutClient {
+	return &SourcehutClient{
+		tokenManager: token.NewManager(tokens),
+		serverConfig: serverConfig,
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (c *SourcehutClient) GetTokenManager() *token.Manager {
+	return c.tokenManager
+}
+
+func (c *SourcehutClient) ListRepositories(ctx context.Context, cfg config.Config) ([]client.Repository, error) {
+	repos, err := c.listRepos(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	trackers := make(map[string]bool)
+	if cfg.IssuesEnabled() {
+		if trackers, err = c.listTrackerNames(ctx, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	allRepos := make([]client.Repository, 0, len(repos))
+	for _, repo := range repos {
+		r := c.toRepository(repo)
+		r.HasIssues = trackers[r.FullName()]
+		allRepos = append(allRepos, r)
+	}
+
+	return allRepos, nil
+}
+
+// FetchIssues exports the tickets of the tracker named after the repository
+func (c *SourcehutClient) FetchIssues(ctx context.Context, repo client.Repository, since time.Time, incremental bool) ([]issues.Issue, error) {
+	return c.fetchTickets(ctx, repo.Owner, repo.Name, since, incremental)
+}
+
+// listRepos returns the repositories of the configured username followed by
+// those of the additional users. Sourcehut has no organizations.
+func (c *SourcehutClient) listRepos(ctx context.Context, cfg config.Config) ([]*repository, error) {
+	var allRepos []*repository
+
+	for _, user := range owners(cfg) {
+		logger.Debugf("Fetching list of repositories for %s ⏳", user)
+
+		var next *string
+		for {
+			var data struct {
+				User *struct {
+					Repositories cursor[*repository] `json:"repositories"`
+				} `json:"user"`
+			}
+			vars := map[string]interface{}{"username": user, "cursor": next}
+			if err := c.query(ctx, c.endpoint("git"), repositoriesQuery, vars, &data); err != nil {
+				return nil, err
+			}
+			if data.User == nil {
+				return nil, fmt.Errorf("user %s not found", user)
+			}
+
+			allRepos = append(allRepos, data.User.Repositories.Results...)
+
+			next = data.User.Repositories.Cursor
+			if next == nil {
+				break
+			}
+		}
+	}
+
+	return allRepos, nil
+}
+
+// owners returns the usernames to back up, without the "~" prefix
+func owners(cfg config.Config) []string {
+	users := []string{strings.TrimPrefix(cfg.Username, "~")}
+	for _, user := range cfg.Users {
+		users = append(users, strings.TrimPrefix(user, "~"))
+	}
+	return users
+}
+
+// endpoint returns the GraphQL endpoint of a sourcehut service
+func (c *SourcehutClient) endpoint(service string) string {
+	domain := strings.TrimSuffix(c.serverConfig.Domain, "/")
+	if service != "git" {
+		domain = service + "." + strings.TrimPrefix(domain, "git.")
+	}
+	return fmt.Sprintf("%s://%s/query", c.serverConfig.Protocol, domain)
+}
+
+func (c *SourcehutClient) cloneURL(repo *repository) string {
+	return fmt.Sprintf("%s://%s/%s/%s", c.serverConfig.Protocol, strings.TrimSuffix(c.serverConfig.Domain, "/"), repo.Owner.CanonicalName, repo.Name)
+}
+
+// query runs a GraphQL query against the API at endpoint and decodes its data
+// into out
+func (c *SourcehutClient) query(ctx context.Context, endpoint, query string, variables map[string]interface{}, out interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return fmt.Errorf("failed to encode query: %v", err)
+	}
+
+	return client.WithTokens(ctx, c.tokenManager, func(ctx context.Context) error {
+		return c.queryOnce(ctx, endpoint, payload, out)
+	})
+}
+
+func (c *SourcehutClient) queryOnce(ctx context.Context, endpoint string, payload []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.tokenManager.GetNextToken())
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %v", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if err := client.CheckResponse(resp, endpoint); err != nil {
+		return err
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %v", endpoint, err)
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("query to %s failed: %s", endpoint, result.Errors[0].Message)
+	}
+
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %v", endpoint, err)
+	}
+
+	return nil
+}
+
+func (c *SourcehutClient) toRepository(repo *repository) client.Repository {
+	visibility := "public"
+	switch repo.Visibility {
+	case "PRIVATE":
+		visibility = "private"
+	case "UNLISTED":
+		// Unlisted repositories are reachable by anyone knowing the URL, which
+		// is the closest to what other platforms call internal
+		visibility = "internal"
+	}
+
+	return client.Repository{
+		ID:         strconv.FormatInt(repo.ID, 10),
+		Owner:      repo.Owner.name(),
+		Name:       repo.Name,
+		CloneURL:   c.cloneURL(repo),
+		Visibility: visibility,
+		PushedAt:   repo.Updated,
+	}
+}
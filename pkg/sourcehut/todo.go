@@ -0,0 +1,276 @@
+package sourcehut
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/issues"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+const trackersQuery = `query trackers($username: String!, $cursor: Cursor) {
+  user(username: $username) {
+    trackers(cursor: $cursor) {
+      results { name }
+      cursor
+    }
+  }
+}`
+
+const ticketsQuery = `query tickets($username: String!, $tracker: String!, $cursor: Cursor) {
+  user(username: $username) {
+    tracker(name: $tracker) {
+      tickets(cursor: $cursor) {
+        results {
+          id subject body status created updated
+          submitter { canonicalName }
+          assignees { canonicalName }
+          labels { name }
+        }
+        cursor
+      }
+    }
+  }
+}`
+
+const eventsQuery = `query events($username: String!, $tracker: String!, $id: Int!, $cursor: Cursor) {
+  user(username: $username) {
+    tracker(name: $tracker) {
+      ticket(id: $id) {
+        events(cursor: $cursor) {
+          results {
+            id created
+            changes { eventType ... on Comment { text author { canonicalName } } }
+          }
+          cursor
+        }
+      }
+    }
+  }
+}`
+
+type ticket struct {
+	ID        int       `json:"id"`
+	Subject   string    `json:"subject"`
+	Body      *string   `json:"body"`
+	Status    string    `json:"status"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	Submitter entity    `json:"submitter"`
+	Assignees []entity  `json:"assignees"`
+	Labels    []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+type event struct {
+	ID      int64     `json:"id"`
+	Created time.Time `json:"created"`
+	Changes []struct {
+		EventType string  `json:"eventType"`
+		Text      string  `json:"text"`
+		Author    *entity `json:"author"`
+	} `json:"changes"`
+}
+
+// listTrackerNames returns the full names (owner/tracker) of the trackers of
+// every backed up user
+func (c *SourcehutClient) listTrackerNames(ctx context.Context, cfg config.Config) (map[string]bool, error) {
+	trackers := make(map[string]bool)
+	for _, user := range owners(cfg) {
+		names, err := c.listTrackers(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			trackers[user+"/"+name] = true
+		}
+	}
+	return trackers, nil
+}
+
+func (c *SourcehutClient) listTrackers(ctx context.Context, user string) ([]string, error) {
+	var names []string
+	var next *string
+
+	for {
+		var data struct {
+			User *struct {
+				Trackers cursor[struct {
+					Name string `json:"name"`
+				}] `json:"trackers"`
+			} `json:"user"`
+		}
+		vars := map[string]interface{}{"username": user, "cursor": next}
+		if err := c.query(ctx, c.endpoint("todo"), trackersQuery, vars, &data); err != nil {
+			return nil, err
+		}
+		if data.User == nil {
+			return nil, fmt.Errorf("user %s not found", user)
+		}
+
+		for _, t := range data.User.Trackers.Results {
+			names = append(names, t.Name)
+		}
+
+		next = data.User.Trackers.Cursor
+		if next == nil {
+			break
+		}
+	}
+
+	return names, nil
+}
+
+// fetchTickets converts the tickets of a tracker into issues. The API cannot
+// filter by update time, so incremental syncs fetch every ticket but only
+// request the comments of those updated since the last sync.
+func (c *SourcehutClient) fetchTickets(ctx context.Context, user, tracker string, since time.Time, incremental bool) ([]issues.Issue, error) {
+	trackerFullName := fmt.Sprintf("%s/%s", user, tracker)
+	if incremental {
+		logger.Debugf("Incremental fetch for %s (tickets updated since %s)", trackerFullName, since.Format(time.RFC3339))
+	} else {
+		logger.Debugf("Full fetch for %s ⏳", trackerFullName)
+	}
+
+	var allIssues []issues.Issue
+	var next *string
+	for {
+		var data struct {
+			User *struct {
+				Tracker *struct {
+					Tickets cursor[ticket] `json:"tickets"`
+				} `json:"tracker"`
+			} `json:"user"`
+		}
+		vars := map[string]interface{}{"username": user, "tracker": tracker, "cursor": next}
+		if err := c.query(ctx, c.endpoint("todo"), ticketsQuery, vars, &data); err != nil {
+			return nil, err
+		}
+		if data.User == nil || data.User.Tracker == nil {
+			return nil, fmt.Errorf("tracker %s not found", trackerFullName)
+		}
+
+		for _, t := range data.User.Tracker.Tickets.Results {
+			if incremental && !t.Updated.After(since) {
+				continue
+			}
+
+			issue := c.convertTicket(user, tracker, t)
+			comments, err := c.fetchComments(ctx, user, tracker, t.ID)
+			if err != nil {
+				logger.Warnf("Failed to fetch comments for ticket #%d in %s: %v", t.ID, trackerFullName, err)
+			} else {
+				issue.Comments = comments
+			}
+			allIssues = append(allIssues, issue)
+		}
+
+		next = data.User.Tracker.Tickets.Cursor
+		if next == nil {
+			break
+		}
+	}
+
+	logger.Debugf("Fetched %d tickets for %s", len(allIssues), trackerFullName)
+	return allIssues, nil
+}
+
+func (c *SourcehutClient) fetchComments(ctx context.Context, user, tracker string, id int) ([]issues.Comment, error) {
+	var comments []issues.Comment
+	var next *string
+
+	for {
+		var data struct {
+			User *struct {
+				Tracker *struct {
+					Ticket *struct {
+						Events cursor[event] `json:"events"`
+					} `json:"ticket"`
+				} `json:"tracker"`
+			} `json:"user"`
+		}
+		vars := map[string]interface{}{"username": user, "tracker": tracker, "id": id, "cursor": next}
+		if err := c.query(ctx, c.endpoint("todo"), eventsQuery, vars, &data); err != nil {
+			return nil, err
+		}
+		if data.User == nil || data.User.Tracker == nil || data.User.Tracker.Ticket == nil {
+			return nil, fmt.Errorf("ticket #%d not found", id)
+		}
+
+		for _, e := range data.User.Tracker.Ticket.Events.Results {
+			for _, change := range e.Changes {
+				if change.EventType != "COMMENT" || change.Author == nil {
+					continue
+				}
+				comments = append(comments, issues.Comment{
+					ID:        e.ID,
+					Body:      change.Text,
+					Author:    c.toUser(*change.Author),
+					URL:       fmt.Sprintf("%s#event-%d", c.ticketURL(user, tracker, id), e.ID),
+					CreatedAt: e.Created,
+					UpdatedAt: e.Created,
+				})
+			}
+		}
+
+		next = data.User.Tracker.Ticket.Events.Cursor
+		if next == nil {
+			break
+		}
+	}
+
+	return comments, nil
+}
+
+func (c *SourcehutClient) convertTicket(user, tracker string, t ticket) issues.Issue {
+	issue := issues.Issue{
+		Number:    t.ID,
+		Title:     t.Subject,
+		State:     strings.ToLower(t.Status),
+		Author:    c.toUser(t.Submitter),
+		URL:       c.ticketURL(user, tracker, t.ID),
+		CreatedAt: t.Created,
+		UpdatedAt: t.Updated,
+	}
+
+	if t.Body != nil {
+		issue.Body = *t.Body
+	}
+
+	// Tickets do not record when they were resolved, the last update is the
+	// closest approximation
+	if t.Status == "RESOLVED" {
+		closedAt := t.Updated
+		issue.ClosedAt = &closedAt
+	}
+
+	labels := make([]string, 0, len(t.Labels))
+	for _, l := range t.Labels {
+		labels = append(labels, l.Name)
+	}
+	issue.Labels = labels
+
+	assignees := make([]issues.User, 0, len(t.Assignees))
+	for _, a := range t.Assignees {
+		assignees = append(assignees, c.toUser(a))
+	}
+	issue.Assignees = assignees
+
+	return issue
+}
+
+func (c *SourcehutClient) ticketURL(user, tracker string, id int) string {
+	return fmt.Sprintf("%s/~%s/%s/%d", c.todoBaseURL(), user, tracker, id)
+}
+
+func (c *SourcehutClient) toUser(e entity) issues.User {
+	return issues.User{Login: e.CanonicalName, URL: fmt.Sprintf("%s/%s", c.todoBaseURL(), e.CanonicalName)}
+}
+
+func (c *SourcehutClient) todoBaseURL() string {
+	return strings.TrimSuffix(c.endpoint("todo"), "/query")
+}
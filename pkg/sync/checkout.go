@@ -0,0 +1,202 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+)
+
+// worktreeRefspecs fetch all branches of clones with a worktree as remote
+// branches, the way a clone sets them up, along with all tags. Forcing them
+// follows force pushes and moved tags.
+var worktreeRefspecs = []string{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"}
+
+// maxListedChanges is how many of the files blocking the update of a worktree
+// are named in the error
+const maxListedChanges = 5
+
+// hasWorktree reports whether clones of the clone type have a worktree
+func hasWorktree(cloneType string) bool {
+	return cloneType == "full" || cloneType == "shallow"
+}
+
+// checkoutDefaultBranch resets the worktree of the clone at repoPath to the
+// fetched default branch of repoURL. Unlike the merge of a pull, a reset
+// follows force pushes, and a default branch that was renamed is checked out
+// under its new name. Uncommitted changes and untracked files in the way of the
+// branch are never overwritten, the update is reported as blocked by them
+// instead.
+func (r *Run) checkoutDefaultBranch(ctx context.Context, config config.Config, repoPath, repoURL string) error {
+	branch, err := r.defaultBranch(ctx, config, repoURL)
+	if err != nil || branch == "" {
+		return err
+	}
+
+	status, err := r.gitFor(ctx, config, repoURL, "-C", repoPath, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return err
+	}
+	if changes := changedFiles(status); len(changes) > 0 {
+		return blockedUpdate("uncommitted changes to", changes)
+	}
+
+	if current, err := r.gitFor(ctx, config, repoURL, "-C", repoPath, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		if current := strings.TrimSpace(string(current)); current != branch {
+			r.log.Infof("Default branch of %s changed from %s to %s", repoPath, current, branch)
+		}
+	}
+
+	remoteBranch := "refs/remotes/origin/" + branch
+	if output, err := r.gitFor(ctx, config, repoURL, "-C", repoPath, "checkout", "--quiet", "-B", branch, remoteBranch); err != nil {
+		// Untracked files are only known to be in the way once git tries
+		if untracked := overwrittenFiles(output); len(untracked) > 0 {
+			return blockedUpdate("untracked", untracked)
+		}
+		return err
+	}
+	_, err = r.gitFor(ctx, config, repoURL, "-C", repoPath, "symbolic-ref", "refs/remotes/origin/HEAD", remoteBranch)
+	return err
+}
+
+// refreshCheckout checks the default branch of the bare repository at
+// repoPath out into its checkout path, as a detached worktree so fetches can
+// keep updating the branch. The HEAD of the repository follows the default
+// branch too, should it be renamed. Changes made to the checkout are
+// discarded.
+func (r *Run) refreshCheckout(ctx context.Context, config config.Config, repoName, repoPath, repoURL string) error {
+	branch, err := r.defaultBranch(ctx, config, repoURL)
+	if err != nil || branch == "" {
+		return err
+	}
+
+	ref := "refs/heads/" + branch
+	if _, err := r.gitFor(ctx, config, repoURL, "--git-dir", repoPath, "symbolic-ref", "HEAD", ref); err != nil {
+		return err
+	}
+
+	path := checkoutPath(repoPath)
+	if IsCheckout(path) {
+		r.log.Debugf("Refreshing checkout of %s: %s", repoName, path)
+		// Reconnects the checkout and the repository after the backup
+		// directory was moved
+		if _, err := r.gitFor(ctx, config, repoURL, "--git-dir", repoPath, "worktree", "repair", path); err != nil {
+			return err
+		}
+		_, err = r.gitFor(ctx, config, repoURL, "-C", path, "checkout", "--quiet", "--force", "--detach", ref)
+		return err
+	}
+
+	// Leftovers of a checkout that was moved or never finished
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	if _, err := r.gitFor(ctx, config, repoURL, "--git-dir", repoPath, "worktree", "prune"); err != nil {
+		return err
+	}
+	r.log.Infof("Checking out %s: %s", repoName, path)
+	_, err = r.gitFor(ctx, config, repoURL, "--git-dir", repoPath, "worktree", "add", "--quiet", "--force", "--detach", path, ref)
+	return err
+}
+
+// gitFor runs a git command on the clone of repoURL under the limits and the
+// fetch timeout of a transfer from it. Checkouts of blobless, partial and
+// shallow-since clones fetch the blobs they are missing.
+func (r *Run) gitFor(ctx context.Context, config config.Config, repoURL string, args ...string) ([]byte, error) {
+	return r.git(ctx, config.Timeouts.Fetch, repoURL, func(ctx context.Context) *exec.Cmd {
+		return gitCommand(ctx, config, args...)
+	})
+}
+
+// defaultBranch returns the default branch of repoURL, or "" for empty
+// repositories that have none
+func (r *Run) defaultBranch(ctx context.Context, config config.Config, repoURL string) (string, error) {
+	output, err := r.git(ctx, config.Timeouts.Fetch, repoURL, func(ctx context.Context) *exec.Cmd {
+		return gitCommand(ctx, config, "ls-remote", "--symref", repoURL, "HEAD")
+	})
+	if err != nil {
+		return "", err
+	}
+	return parseDefaultBranch(output), nil
+}
+
+// checkoutPath returns the checkout path of the bare repository at repoPath.
+// Its name cannot clash with the repository, its wiki or its issues, and
+// being hidden, neither with the directory of a nested group or repository.
+func checkoutPath(repoPath string) string {
+	return filepath.Join(filepath.Dir(repoPath), ".checkout")
+}
+
+// IsCheckout reports whether path holds the checkout of a bare repository,
+// a worktree whose .git is a file pointing to the repository
+func IsCheckout(path string) bool {
+	info, err := os.Stat(filepath.Join(path, ".git"))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// parseDefaultBranch returns the branch HEAD points to in the output of
+// git ls-remote --symref, or "" when HEAD is missing
+func parseDefaultBranch(output []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		target, ref, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok || ref != "HEAD" {
+			continue
+		}
+		if branch, ok := strings.CutPrefix(target, "ref: refs/heads/"); ok {
+			return branch
+		}
+	}
+	return ""
+}
+
+// blockedUpdate returns the permanent error of a worktree update that would
+// overwrite files, naming the first few of them
+func blockedUpdate(kind string, files []string) error {
+	listed := files[:min(len(files), maxListedChanges)]
+	return &permanentError{fmt.Errorf("%s %d files block the update of the worktree: %s",
+		kind, len(files), strings.Join(listed, ", "))}
+}
+
+// overwrittenFiles returns the untracked files a failed git checkout refused
+// to overwrite, which it lists indented below its error
+func overwrittenFiles(output []byte) []string {
+	var files []string
+	listing := false
+	for _, line := range strings.Split(string(output), "\n") {
+		switch {
+		case strings.Contains(line, "untracked working tree files would be"):
+			listing = true
+		case listing && strings.HasPrefix(line, "\t"):
+			files = append(files, strings.TrimPrefix(line, "\t"))
+		default:
+			listing = false
+		}
+	}
+	return files
+}
+
+// changedFiles returns the files in the output of git status --porcelain,
+// renamed and copied files by their new path
+func changedFiles(status []byte) []string {
+	var files []string
+	for _, line := range strings.Split(string(status), "\n") {
+		if len(line) <= 3 {
+			continue
+		}
+		file := line[3:]
+		if strings.ContainsAny(line[:2], "RC") {
+			if _, to, ok := strings.Cut(file, " -> "); ok {
+				file = to
+			}
+		}
+		files = append(files, file)
+	}
+	return files
+}
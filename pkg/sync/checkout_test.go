@@ -0,0 +1,130 @@
+package sync
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseDefaultBranch(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "HEAD points to a branch",
+			output: "ref: refs/heads/main\tHEAD\n1f0d5c2b4e3a9f8d7c6b5a4e3d2c1b0a9f8e7d6c\tHEAD\n",
+			want:   "main",
+		},
+		{
+			name:   "Branch with a slash",
+			output: "ref: refs/heads/release/v1\tHEAD\n1f0d5c2b4e3a9f8d7c6b5a4e3d2c1b0a9f8e7d6c\tHEAD\n",
+			want:   "release/v1",
+		},
+		{
+			name:   "No HEAD",
+			output: "",
+			want:   "",
+		},
+		{
+			name:   "Detached HEAD",
+			output: "1f0d5c2b4e3a9f8d7c6b5a4e3d2c1b0a9f8e7d6c\tHEAD\n",
+			want:   "",
+		},
+		{
+			name:   "Other refs only",
+			output: "ref: refs/heads/main\trefs/remotes/origin/HEAD\n",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDefaultBranch([]byte(tt.output)); got != tt.want {
+				t.Errorf("parseDefaultBranch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverwrittenFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			name: "Listing followed by advice",
+			output: "error: The following untracked working tree files would be overwritten by checkout:\n" +
+				"\tREADME.md\n\tdocs/guide.md\n" +
+				"Please move or remove them before you switch branches.\nAborting\n",
+			want: []string{"README.md", "docs/guide.md"},
+		},
+		{
+			name: "Listing at the end of the output",
+			output: "error: The following untracked working tree files would be removed by checkout:\n" +
+				"\tbuild/output.bin\n\tbuild/cache",
+			want: []string{"build/output.bin", "build/cache"},
+		},
+		{
+			name:   "Other failure",
+			output: "fatal: reference is not a tree: main\n\tindented but unrelated\n",
+			want:   nil,
+		},
+		{
+			name:   "Empty output",
+			output: "",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := overwrittenFiles([]byte(tt.output)); !slices.Equal(got, tt.want) {
+				t.Errorf("overwrittenFiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		want   []string
+	}{
+		{
+			name:   "Modified and untracked",
+			status: " M README.md\n?? notes.txt\n",
+			want:   []string{"README.md", "notes.txt"},
+		},
+		{
+			name:   "Rename",
+			status: "R  a -> b\n",
+			want:   []string{"b"},
+		},
+		{
+			name:   "Copy",
+			status: "C  src/a.go -> src/b.go\n",
+			want:   []string{"src/b.go"},
+		},
+		{
+			name:   "No trailing newline",
+			status: "D  removed.go\nAM added.go",
+			want:   []string{"removed.go", "added.go"},
+		},
+		{
+			name:   "Clean",
+			status: "",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changedFiles([]byte(tt.status)); !slices.Equal(got, tt.want) {
+				t.Errorf("changedFiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
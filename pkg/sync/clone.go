@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+// tempDirPrefix starts the name of the directories clones are made in before
+// they are moved into place
+const tempDirPrefix = ".git-sync-tmp-"
+
+// IsTempDir reports whether a directory name belongs to a clone in progress,
+// or to one that was interrupted before it could be cleaned up
+func IsTempDir(name string) bool {
+	return strings.HasPrefix(name, tempDirPrefix)
+}
+
+// cloneAtomically runs clone with a path inside a temporary sibling directory
+// of path and moves the result into place only when it succeeds, so a failed
+// or interrupted clone never leaves a broken repository at path.
+func cloneAtomically(path string, clone func(tmpPath string) error) error {
+	parent := filepath.Dir(path)
+	if err := os.MkdirAll(parent, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Leftovers of a clone that was killed before it could clean up
+	stale, _ := filepath.Glob(filepath.Join(parent, tempDirPrefix+filepath.Base(path)+"-*"))
+	for _, dir := range stale {
+		logger.Debugf("Removing leftovers of an interrupted clone: %s", dir)
+		os.RemoveAll(dir)
+	}
+
+	tmpDir, err := os.MkdirTemp(parent, tempDirPrefix+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmpPath := filepath.Join(tmpDir, filepath.Base(path))
+	if err := clone(tmpPath); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// needsClone reports whether path has to be cloned because it does not exist.
+// A directory that is not a usable git repository, such as one left behind by
+// a clone interrupted before clones were atomic, is removed so it is cloned
+// again instead of failing every update.
+func needsClone(path string) bool {
+	if _, err := os.Stat(path); err != nil {
+		// Other errors are left for the update to report
+		return os.IsNotExist(err)
+	}
+
+	if IsRepository(path) {
+		return false
+	}
+
+	logger.Warnf("%s is not a valid git repository, cloning it again", path)
+	if err := os.RemoveAll(path); err != nil {
+		logger.Warnf("Failed to remove %s: %v", path, err)
+		return false
+	}
+	return true
+}
+
+// IsRepository reports whether path holds a bare repository or a worktree,
+// judged by the HEAD file and objects directory every repository has
+func IsRepository(path string) bool {
+	for _, gitDir := range []string{path, filepath.Join(path, ".git")} {
+		head, err := os.Stat(filepath.Join(gitDir, "HEAD"))
+		if err != nil || head.IsDir() {
+			continue
+		}
+		objects, err := os.Stat(filepath.Join(gitDir, "objects"))
+		if err == nil && objects.IsDir() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+// runTestGit runs git in dir and fails the test when it does not succeed
+func runTestGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+}
+
+// newRemote returns the path of a bare repository with a single commit on
+// main, to be cloned from instead of a platform
+func newRemote(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	remote := filepath.Join(dir, "remote.git")
+	runTestGit(t, dir, "init", "--quiet", work)
+	runTestGit(t, work, "commit", "--quiet", "--allow-empty", "-m", "initial")
+	runTestGit(t, dir, "clone", "--quiet", "--bare", work, remote)
+	return remote
+}
+
+// tempDirs returns the temporary clone directories left in dir
+func tempDirs(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if IsTempDir(entry.Name()) {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	return dirs
+}
+
+func TestCloneAtomically(t *testing.T) {
+	logger.InitLogger("fatal")
+	remote := newRemote(t)
+
+	tests := []struct {
+		name      string
+		remote    string
+		leftovers []string
+		wantErr   bool
+	}{
+		{
+			name:   "Clone is moved into place",
+			remote: remote,
+		},
+		{
+			name:      "Leftovers of interrupted clones are removed",
+			remote:    remote,
+			leftovers: []string{tempDirPrefix + "repo.git-123", tempDirPrefix + "repo.git-456"},
+		},
+		{
+			name:    "Failed clone leaves nothing behind",
+			remote:  filepath.Join(t.TempDir(), "missing.git"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := filepath.Join(t.TempDir(), "alice", "repo")
+			path := filepath.Join(parent, "repo.git")
+			for _, dir := range tt.leftovers {
+				if err := os.MkdirAll(filepath.Join(parent, dir, "repo.git"), os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var tmpPath string
+			err := cloneAtomically(path, func(clonePath string) error {
+				tmpPath = clonePath
+				if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("%s exists before the clone finished", path)
+				}
+				return exec.Command("git", "clone", "--quiet", "--mirror", tt.remote, clonePath).Run()
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cloneAtomically() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if filepath.Dir(filepath.Dir(tmpPath)) != parent || !IsTempDir(filepath.Base(filepath.Dir(tmpPath))) {
+				t.Errorf("Cloned into %s, want a temporary directory inside %s", tmpPath, parent)
+			}
+			if got := IsRepository(path); got == tt.wantErr {
+				t.Errorf("IsRepository(%s) = %v, want %v", path, got, !tt.wantErr)
+			}
+			if dirs := tempDirs(t, parent); len(dirs) != 0 {
+				t.Errorf("Temporary directories left behind: %v", dirs)
+			}
+		})
+	}
+}
+
+func TestNeedsClone(t *testing.T) {
+	logger.InitLogger("fatal")
+	remote := newRemote(t)
+
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, path string)
+		want        bool
+		wantRemoved bool
+	}{
+		{
+			name:  "Missing",
+			setup: func(t *testing.T, path string) {},
+			want:  true,
+		},
+		{
+			name: "Bare repository",
+			setup: func(t *testing.T, path string) {
+				runTestGit(t, filepath.Dir(path), "clone", "--quiet", "--mirror", remote, path)
+			},
+			want: false,
+		},
+		{
+			name: "Worktree",
+			setup: func(t *testing.T, path string) {
+				runTestGit(t, filepath.Dir(path), "clone", "--quiet", remote, path)
+			},
+			want: false,
+		},
+		{
+			name: "Empty directory",
+			setup: func(t *testing.T, path string) {
+				if err := os.Mkdir(path, os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:        true,
+			wantRemoved: true,
+		},
+		{
+			name: "Interrupted clone without objects",
+			setup: func(t *testing.T, path string) {
+				if err := os.Mkdir(path, os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(path, "HEAD"), []byte("ref: refs/heads/main\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:        true,
+			wantRemoved: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "repo.git")
+			tt.setup(t, path)
+
+			if got := needsClone(path); got != tt.want {
+				t.Errorf("needsClone() = %v, want %v", got, tt.want)
+			}
+			if tt.wantRemoved {
+				if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("%s was not removed: %v", path, err)
+				}
+			}
+		})
+	}
+}
@@ -1,22 +1,34 @@
 package sync
 
 import (
+	"context"
 	"sync"
 
 	"github.com/AkashRajpurohit/git-sync/pkg/config"
 )
 
-func SyncWithConcurrency[T any](cfg config.Config, repos []T, syncFn func(T)) {
+// SyncWithConcurrency runs syncFn for every repo, at most cfg.Concurrency at a
+// time. Repos are started in the order they are given. Once ctx is cancelled
+// no new repos are started, while running ones are waited for.
+func SyncWithConcurrency[T any](ctx context.Context, cfg config.Config, repos []T, syncFn func(T)) {
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, cfg.Concurrency)
 
 	for _, repo := range repos {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		// The semaphore may have been acquired after ctx was cancelled
+		if ctx.Err() != nil {
+			break
+		}
+
 		wg.Add(1)
 		go func(r T) {
 			defer wg.Done()
-			sem <- struct{}{}
+			defer func() { <-sem }()
 			syncFn(r)
-			<-sem
 		}(repo)
 	}
 
@@ -0,0 +1,131 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/helpers"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/usage"
+)
+
+// ExceedsMaxRepoSize reports whether the platform reports repo to be larger
+// than max_repo_size. Repositories of unknown size never are.
+func ExceedsMaxRepoSize(cfg config.Config, repo client.Repository) bool {
+	return cfg.MaxRepoSize > 0 && repo.SizeKB > int64(cfg.MaxRepoSize)*1024
+}
+
+// warnUnknownSizes warns that max_repo_size is not enforced when none of the
+// repos listed by a platform has a size, as Bitbucket and Sourcehut do not
+// report them. Raw repositories and containers never have one.
+func warnUnknownSizes(cfg config.Config, repos []client.Repository, log *logger.Logger) {
+	if cfg.MaxRepoSize <= 0 {
+		return
+	}
+
+	listed := false
+	for _, repo := range repos {
+		if repo.Raw || repo.Container {
+			continue
+		}
+		if repo.SizeKB > 0 {
+			return
+		}
+		listed = true
+	}
+	if listed {
+		log.Warn("max_repo_size is not enforced, the platform does not report the size of repositories")
+	}
+}
+
+// applyQuota enforces max_repo_size and min_free_space on a repository that
+// is not backed up yet, existing backups keep being updated. It returns cfg
+// with the clone type to use for repo, or false when repo is not cloned.
+func (r *Run) applyQuota(cfg config.Config, repo client.Repository) (config.Config, bool) {
+	repoPath := RepoPath(repo.Owner, repo.Name, cfg)
+	if IsRepository(repoPath) {
+		// Repositories shallow cloned because of their size stay shallow, their
+		// layout differs from that of the other clone types
+		if cfg.OversizeAction == "shallow" && isShallowClone(repoPath) {
+			cfg.CloneType = "shallow"
+		}
+		return cfg, true
+	}
+
+	if cfg.MinFreeSpace > 0 {
+		// Overrides may move repositories into directories that do not
+		// exist yet, the backup directory itself always does
+		free, err := usage.FreeSpace(r.syncer.cfg.BackupDir)
+		if err != nil {
+			r.log.Debugf("Failed to measure the free disk space, min_free_space is not enforced: %v", err)
+		} else if free < int64(cfg.MinFreeSpace)*1024*1024 {
+			r.log.Errorf("Not cloning %s, only %s of disk space is free", repo.FullName(), helpers.FormatSize(free))
+			r.stats.recordLowSpace(repo.FullName())
+			return cfg, false
+		}
+	}
+
+	if !ExceedsMaxRepoSize(cfg, repo) {
+		return cfg, true
+	}
+	size := helpers.FormatSize(repo.SizeKB * 1024)
+	if cfg.OversizeAction == "shallow" {
+		r.log.Infof("Cloning only the last commit of %s, it takes %s", repo.FullName(), size)
+		cfg.CloneType = "shallow"
+		return cfg, true
+	}
+	r.log.Warnf("Not cloning %s, it takes %s", repo.FullName(), size)
+	r.stats.recordTooLarge(repo.FullName())
+	return cfg, false
+}
+
+// isShallowClone reports whether the repository at repoPath was cloned with
+// the shallow clone type
+func isShallowClone(repoPath string) bool {
+	_, err := os.Stat(filepath.Join(repoPath, ".git", "shallow"))
+	return err == nil
+}
+
+// recordUsage measures the disk space the backups of the repos of a source
+// take, namespace by namespace, and saves it to the usage state file
+func (r *Run) recordUsage(cfg config.Config, source string, namespaces []string, byNamespace map[string][]client.Repository) {
+	u := usage.New()
+	for _, namespace := range namespaces {
+		nsCfg := NamespaceConfig(cfg, namespace)
+		for _, repo := range byNamespace[namespace] {
+			repoCfg := r.syncer.overrides.Apply(nsCfg, repo)
+			// Only what belongs to the repository is measured, as the
+			// directories of nested groups hold other repositories too
+			var size int64
+			for _, path := range []string{
+				RepoPath(repo.Owner, repo.Name, repoCfg),
+				WikiPath(repo.Owner, repo.Name, repoCfg),
+				CheckoutPath(repo.Owner, repo.Name, repoCfg),
+				filepath.Join(getBaseDirectoryPath(repo.Owner, repo.Name, repoCfg), "issues"),
+			} {
+				size += usage.DirSize(path)
+			}
+			u.AddRepo(namespace, repo.Owner, repo.Name, size)
+		}
+	}
+	u.AddPools(usage.DirSize(filepath.Join(cfg.BackupDir, poolDirName, source)))
+	u.UpdatedAt = time.Now()
+
+	owners := make([]string, 0, len(u.Owners))
+	for owner := range u.Owners {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool { return u.Owners[owners[i]] > u.Owners[owners[j]] })
+	for _, owner := range owners {
+		r.log.Debugf("Disk usage of %s: %s", owner, helpers.FormatSize(u.Owners[owner]))
+	}
+
+	r.stats.recordDiskUsage(u.Total)
+	if err := usage.Save(cfg.BackupDir, source, u); err != nil {
+		r.log.Warnf("Failed to save the disk usage: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/helpers"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/notification"
+	"github.com/AkashRajpurohit/git-sync/pkg/usage"
+)
+
+// MaintenanceReport sums up a maintenance run
+type MaintenanceReport struct {
+	mu         sync.Mutex
+	Maintained int
+	Failed     []string
+	Reclaimed  int64 // bytes, negative when the repositories grew
+}
+
+func (report *MaintenanceReport) record(path string, reclaimed int64, err error) {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	if err != nil {
+		report.Failed = append(report.Failed, fmt.Sprintf("%s (Error: %v)", path, err))
+		return
+	}
+	report.Maintained++
+	report.Reclaimed += reclaimed
+}
+
+// Summary returns the report as it is included in notifications
+func (report *MaintenanceReport) Summary() *notification.MaintenanceSummary {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	return &notification.MaintenanceSummary{
+		Maintained: report.Maintained,
+		Failed:     append([]string(nil), report.Failed...),
+		Reclaimed:  report.Reclaimed,
+	}
+}
+
+// maintainable is a repository found in the backup directory
+type maintainable struct {
+	path string
+	// pool is set for object pools, which forks borrow objects from. They
+	// are pruned only after the refs of the forks are fetched again.
+	pool bool
+}
+
+// Maintain runs the git maintenance tasks on every repository in the backup
+// directory, at most maintenance.concurrency at a time, and prunes the
+// unreachable objects older than maintenance.prune_expire. Once ctx is
+// cancelled no further repositories are started, those already running get
+// shutdown_timeout to finish.
+func Maintain(ctx context.Context, cfg config.Config) (*MaintenanceReport, error) {
+	log := logger.With("phase", "maintenance")
+	repos, err := findMaintainable(cfg.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Maintaining %d repositories in %s 🧹", len(repos), cfg.BackupDir)
+
+	workCtx, cancel := drainContext(ctx, time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+
+	report := &MaintenanceReport{}
+	concurrency := cfg
+	concurrency.Concurrency = max(cfg.Maintenance.Concurrency, 1)
+	SyncWithConcurrency(ctx, concurrency, repos, func(repo maintainable) {
+		objects := filepath.Join(gitDirOf(repo.path), "objects")
+		before := usage.DirSize(objects)
+		err := maintain(workCtx, cfg, repo)
+		reclaimed := before - usage.DirSize(objects)
+
+		if err != nil {
+			log.Errorf("Failed to maintain %s: %v", repo.path, err)
+		} else {
+			log.Debugf("Maintained %s, reclaimed %s", repo.path, helpers.FormatSize(reclaimed))
+		}
+		report.record(repo.path, reclaimed, err)
+	})
+
+	if ctx.Err() != nil {
+		log.Warn("Shutdown requested, remaining repositories were not maintained")
+	}
+	log.Infof("Maintenance finished: %d repositories maintained, %d failed, %s reclaimed",
+		report.Maintained, len(report.Failed), helpers.FormatSize(report.Reclaimed))
+	for _, failed := range report.Failed {
+		log.Errorf("Failed to maintain: %s", failed)
+	}
+	return report, nil
+}
+
+// maintain runs the maintenance tasks on a single repository
+func maintain(ctx context.Context, cfg config.Config, repo maintainable) error {
+	gitDir := gitDirOf(repo.path)
+
+	// The tasks pack loose objects, combine small packfiles and keep the
+	// commit-graph up to date. None of them drops an object that is still in
+	// a packfile. Forks that borrow all their objects and empty repositories
+	// have no packfiles to combine.
+	args := []string{"--git-dir", gitDir, "maintenance", "run", "--quiet", "--task=loose-objects", "--task=commit-graph"}
+	if packs, _ := filepath.Glob(filepath.Join(gitDir, "objects", "pack", "*.pack")); len(packs) > 0 {
+		args = append(args, "--task=incremental-repack")
+	}
+	if _, err := runGit(gitCommand(ctx, cfg, args...)); err != nil {
+		return err
+	}
+
+	if repo.pool {
+		return prunePool(ctx, cfg, gitDir)
+	}
+	if cfg.Maintenance.PruneExpire == "never" {
+		return nil
+	}
+	_, err := runGit(gitCommand(ctx, cfg, "--git-dir", gitDir, "prune", "--expire", cfg.Maintenance.PruneExpire))
+	return err
+}
+
+// findMaintainable returns the repositories and wikis in the backup directory
+// and the object pools of the fork networks. Issues, checkouts and clones in
+// progress are left out.
+func findMaintainable(backupDir string) ([]maintainable, error) {
+	pools := filepath.Join(backupDir, poolDirName)
+	var repos []maintainable
+
+	err := filepath.WalkDir(backupDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == backupDir {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if !d.IsDir() || path == backupDir {
+			return nil
+		}
+
+		name := d.Name()
+		if name == "issues" || IsTempDir(name) || IsCheckout(path) {
+			return filepath.SkipDir
+		}
+		if !strings.HasSuffix(name, ".git") {
+			return nil
+		}
+
+		if IsRepository(path) {
+			repos = append(repos, maintainable{path: path, pool: strings.HasPrefix(path, pools+string(filepath.Separator))})
+		}
+		return filepath.SkipDir
+	})
+
+	return repos, err
+}
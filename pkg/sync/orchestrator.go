@@ -0,0 +1,338 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/filter"
+	"github.com/AkashRajpurohit/git-sync/pkg/issues"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/notification"
+	"github.com/AkashRajpurohit/git-sync/pkg/queue"
+	"github.com/AkashRajpurohit/git-sync/pkg/throttle"
+)
+
+// Candidate is a discovered repository together with the filter decision
+type Candidate struct {
+	client.Repository
+	Decision filter.Decision
+}
+
+// Discover lists the repositories of a client and decides which of them are
+// backed up. Repositories are kept the first time they are listed only, with
+// those outside of a namespace taking precedence. Namespaced repositories are
+// also subject to starred_max_size_mb, and HasIssues is cleared for clients
+// that cannot fetch issues.
+func Discover(ctx context.Context, cfg config.Config, c client.Client) ([]Candidate, error) {
+	return discover(ctx, cfg, c, nil, logger.With("phase", "discovery"))
+}
+
+// discover is Discover with the API requests of the listing run in the slots
+// of t, unless it is nil
+func discover(ctx context.Context, cfg config.Config, c client.Client, t *throttle.Throttle, log *logger.Logger) ([]Candidate, error) {
+	repoFilter, err := filter.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := c.ListRepositories(client.WithRequester(ctx, apiRequester(cfg, t, log)), cfg)
+	if err != nil {
+		return nil, err
+	}
+	warnUnknownSizes(cfg, repos, log)
+
+	_, canFetchIssues := c.(client.IssueFetcher)
+	seen := make(map[string]bool, len(repos))
+	var candidates []Candidate
+
+	// Repositories outside of a namespace go first so they win the deduplication
+	for _, namespaced := range []bool{false, true} {
+		for _, repo := range repos {
+			if (repo.Namespace != "") != namespaced {
+				continue
+			}
+			if seen[repo.FullName()] {
+				logger.Debugf("Repo already discovered: %s", repo.FullName())
+				continue
+			}
+			seen[repo.FullName()] = true
+
+			repo.HasIssues = repo.HasIssues && canFetchIssues
+			candidates = append(candidates, Candidate{Repository: repo, Decision: decide(cfg, repoFilter, repo)})
+		}
+	}
+
+	// Containers follow the repositories stored beneath them, unless they are
+	// excluded themselves
+	included := make(map[string]bool)
+	for _, candidate := range candidates {
+		if !candidate.Container && candidate.Decision.Included {
+			included[candidate.Owner] = true
+		}
+	}
+	for i, candidate := range candidates {
+		if !candidate.Container {
+			continue
+		}
+		decision := repoFilter.EvaluateExcludes(candidate.Repository)
+		if decision.Included && !included[candidate.FullName()] {
+			decision = filter.Decision{Rule: "no included repository"}
+		}
+		if decision.Included {
+			logger.Debug("Container included: ", candidate.FullName())
+		} else {
+			logger.Debugf("[%s] Container excluded: %s", decision.Rule, candidate.FullName())
+		}
+		candidates[i].Decision = decision
+	}
+
+	return candidates, nil
+}
+
+// apiRequester runs every API request of a listing on its own like the issue
+// exports are: in a slot of t, unless it is nil, limited to timeouts.api and
+// retried as configured under retry
+func apiRequester(cfg config.Config, t *throttle.Throttle, log *logger.Logger) client.Requester {
+	return func(ctx context.Context, request func(ctx context.Context) error) error {
+		return retry(ctx, cfg.Retry, log, func() error {
+			if t != nil {
+				release, err := t.API(ctx)
+				if err != nil {
+					return err
+				}
+				defer release()
+			}
+			return withTimeout(ctx, cfg.Timeouts.API, request)
+		}, "API request")
+	}
+}
+
+func decide(cfg config.Config, repoFilter *filter.Filter, repo client.Repository) filter.Decision {
+	if repo.Raw || repo.Container {
+		// Containers are decided once the repositories beneath them are
+		return filter.Decision{Included: true}
+	}
+
+	decision := repoFilter.Decide(repo)
+	if decision.Included && repo.Namespace != "" && cfg.StarredMaxSizeMB > 0 && repo.SizeKB > int64(cfg.StarredMaxSizeMB)*1024 {
+		logger.Debugf("[starred_max_size_mb] Repo excluded: %s", repo.FullName())
+		return filter.Decision{Rule: "starred_max_size_mb"}
+	}
+	return decision
+}
+
+// NamespaceConfig returns a copy of cfg with the backup directory pointing
+// inside the namespace
+func NamespaceConfig(cfg config.Config, namespace string) config.Config {
+	if namespace != "" {
+		cfg.BackupDir = filepath.Join(cfg.BackupDir, namespace)
+	}
+	return cfg
+}
+
+// Sync discovers the repositories of a client and backs up the included ones
+// along with their wikis and issues, as a run of its own. name identifies the
+// source in the logs. The summary of the run is returned, or nil when the sync
+// was cancelled before any repository was synced.
+//
+// Repositories are synced in the order of queue.order. Once ctx is cancelled
+// no further repositories are started. Those already running get
+// shutdown_timeout to finish before they are interrupted, and the next sync
+// resumes with the rest when queue.resume is set. The disk usage of the
+// backups is measured after a sync that was not interrupted.
+func (s *Syncer) Sync(ctx context.Context, name string, c client.Client) (*notification.SyncSummary, error) {
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+
+	cfg := s.cfg
+	r := s.newRun(name)
+
+	candidates, err := discover(ctx, cfg, c, s.throttle, r.log)
+	if err != nil {
+		if ctx.Err() != nil {
+			r.log.Warnf("Shutdown requested, %s sync cancelled", name)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	workCtx, cancel := drainContext(ctx, time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+
+	q := queue.Open(cfg.BackupDir, name, cfg.Queue.Resume)
+
+	var namespaces []string
+	byNamespace := make(map[string][]client.Repository)
+	for _, candidate := range candidates {
+		if !candidate.Decision.Included {
+			continue
+		}
+		if _, ok := byNamespace[candidate.Namespace]; !ok {
+			namespaces = append(namespaces, candidate.Namespace)
+		}
+		byNamespace[candidate.Namespace] = append(byNamespace[candidate.Namespace], candidate.Repository)
+	}
+	if len(namespaces) == 0 {
+		r.logRepoCount(0, name)
+	}
+
+	pools := make(map[string]string)
+	if cfg.DeduplicateForks {
+		var included []client.Repository
+		for _, namespace := range namespaces {
+			r.resolveForks(ctx, cfg, c, byNamespace[namespace])
+			included = append(included, byNamespace[namespace]...)
+		}
+		for repo, root := range forkNetworks(included) {
+			pool, err := poolPath(cfg.BackupDir, name, root)
+			if err != nil {
+				return nil, err
+			}
+			pools[repo] = pool
+		}
+	}
+
+	for _, namespace := range namespaces {
+		repos := byNamespace[namespace]
+
+		count := 0
+		for _, repo := range repos {
+			if !repo.Container {
+				count++
+			}
+		}
+		label := name
+		if namespace != "" {
+			label = strings.TrimPrefix(namespace, "_")
+		}
+		r.logRepoCount(count, label)
+
+		pending := make([]client.Repository, 0, len(repos))
+		for _, repo := range repos {
+			if !q.Resumed(repo) {
+				pending = append(pending, repo)
+			}
+		}
+		if err := q.Sort(cfg.Queue, pending, s.overrides.Prioritized); err != nil {
+			return nil, err
+		}
+
+		nsCfg := NamespaceConfig(cfg, namespace)
+		SyncWithConcurrency(ctx, nsCfg, pending, func(repo client.Repository) {
+			q.Done(repo, r.syncRepository(workCtx, nsCfg, c, repo, pools[strings.ToLower(repo.FullName())]))
+		})
+	}
+
+	if ctx.Err() != nil {
+		r.log.Warnf("Shutdown requested, remaining %s repositories were skipped", name)
+	} else {
+		q.Finish()
+		r.recordUsage(cfg, name, namespaces, byNamespace)
+	}
+
+	return r.logSummary(), nil
+}
+
+type shutdownKey struct{}
+
+// shutdownRequested returns a channel that is closed once the sync the
+// operation running with ctx belongs to is asked to shut down
+func shutdownRequested(ctx context.Context) <-chan struct{} {
+	if stop, ok := ctx.Value(shutdownKey{}).(context.Context); ok {
+		return stop.Done()
+	}
+	return ctx.Done()
+}
+
+// drainContext returns a context for the operations running when ctx is
+// cancelled. It is cancelled itself timeout later, interrupting whatever has
+// not finished by then.
+func drainContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	drainCtx, cancel := context.WithCancel(context.WithValue(context.WithoutCancel(ctx), shutdownKey{}, ctx))
+	stop := context.AfterFunc(ctx, func() {
+		logger.Infof("Waiting up to %s for running operations to finish ⏳", timeout)
+		time.AfterFunc(timeout, cancel)
+	})
+
+	return drainCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// syncRepository backs up a repository along with its wiki and issues, with
+// the overrides matching it applied to cfg. Forks share their objects through
+// the object pool at pool when their clone type allows it. New repositories
+// are subject to max_repo_size and min_free_space. It returns the errors of
+// the parts that failed.
+func (r *Run) syncRepository(ctx context.Context, cfg config.Config, c client.Client, repo client.Repository, pool string) error {
+	cfg = r.syncer.overrides.Apply(cfg, repo)
+	if !repo.Container {
+		var ok bool
+		if cfg, ok = r.applyQuota(cfg, repo); !ok {
+			return nil
+		}
+	}
+	if !sharesObjects(cfg.CloneType) {
+		pool = ""
+	}
+	var errs []error
+
+	if !repo.Container {
+		var err error
+		switch {
+		case repo.Raw:
+			err = r.cloneOrUpdateRawRepo(ctx, repo.Owner, repo.Name, repo.CloneURL, cfg)
+		case repo.CloneURL == "":
+			err = r.cloneOrUpdateRepo(ctx, repo.Owner, repo.Name, pool, cfg)
+		default:
+			err = r.cloneOrUpdateRepoFromURL(ctx, repo.Owner, repo.Name, repo.CloneURL, pool, cfg)
+		}
+		errs = append(errs, err)
+	}
+
+	if cfg.IncludeWiki && repo.HasWiki {
+		if repo.WikiURL == "" {
+			errs = append(errs, r.syncWiki(ctx, repo.Owner, repo.Name, cfg))
+		} else {
+			errs = append(errs, r.syncWikiFromURL(ctx, repo.Owner, repo.Name, repo.WikiURL, cfg))
+		}
+	}
+
+	if fetcher, ok := c.(client.IssueFetcher); ok && cfg.IncludeIssues && repo.HasIssues {
+		errs = append(errs, r.fetchAndSyncIssues(ctx, cfg, fetcher, repo))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *Run) fetchAndSyncIssues(ctx context.Context, cfg config.Config, fetcher client.IssueFetcher, repo client.Repository) error {
+	since, hasPrevSync := issues.ReadLastSyncTime(cfg.BackupDir, repo.Owner, repo.Name)
+	var allIssues []issues.Issue
+	err := r.retryOperation(ctx, cfg, func() error {
+		release, err := r.syncer.throttle.API(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return withTimeout(ctx, cfg.Timeouts.API, func(ctx context.Context) error {
+			var err error
+			allIssues, err = fetcher.FetchIssues(ctx, repo, since, hasPrevSync)
+			return err
+		})
+	}, fmt.Sprintf("fetch issues %s", repo.FullName()))
+	if err != nil {
+		r.log.Errorf("Failed to fetch issues for %s: %v", repo.FullName(), err)
+		r.stats.recordIssuesFailure(repo.FullName(), err)
+		return err
+	}
+	return r.syncIssues(ctx, repo.Owner, repo.Name, allIssues, cfg)
+}
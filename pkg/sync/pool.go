@@ -0,0 +1,419 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+// Forks share their objects through an object pool per fork network, a bare
+// repository the members borrow objects from with git alternates. Members do
+// not borrow from each other, so pruning one never breaks another. The pool
+// fetches the refs of every member, keeping whatever a member borrows
+// reachable, and records where the member is kept. It is only pruned by
+// maintenance, which no sync runs alongside, once the refs of every member are
+// fetched again and those of members that left or were deleted are dropped.
+
+// poolDirName is the directory inside the backup directory the object pools
+// are kept in
+const poolDirName = ".git-sync-pools"
+
+// IsPoolDir reports whether a directory name is the one object pools are kept
+// in
+func IsPoolDir(name string) bool {
+	return name == poolDirName
+}
+
+// sharesObjects reports whether clones of the clone type can borrow objects
+// from a pool. Partial and shallow clones cannot.
+func sharesObjects(cloneType string) bool {
+	return cloneType == "bare" || cloneType == "mirror" || cloneType == "full"
+}
+
+// forkNetworks maps the lowercased full name of every repository in a fork
+// network to the root of the network, the repository furthest up the fork
+// chain. Roots that are not in repos themselves still name the network their
+// forks share. Chains that loop back on themselves, which only broken
+// listings have, are rooted at the lowest name in the loop.
+func forkNetworks(repos []client.Repository) map[string]string {
+	parents := make(map[string]string)
+	for _, repo := range repos {
+		if repo.ForkOf != "" {
+			parents[strings.ToLower(repo.FullName())] = strings.ToLower(repo.ForkOf)
+		}
+	}
+
+	rootOf := func(name string) string {
+		chain := []string{name}
+		for parents[name] != "" {
+			name = parents[name]
+			if i := slices.Index(chain, name); i >= 0 {
+				return slices.Min(chain[i:])
+			}
+			chain = append(chain, name)
+		}
+		return name
+	}
+
+	networks := make(map[string]string)
+	for fork := range parents {
+		root := rootOf(fork)
+		networks[fork] = root
+		networks[root] = root
+	}
+	return networks
+}
+
+// poolPath returns where the object pool of the fork network rooted at root
+// is kept. It is absolute, as git alternates are relative to the repository
+// otherwise.
+func poolPath(backupDir, source, root string) (string, error) {
+	return filepath.Abs(filepath.Join(backupDir, poolDirName, source, root+".git"))
+}
+
+// resolveForks fills in which repository the forks in repos were forked from
+// when the listing left it out. Forks it cannot be found out for are backed
+// up on their own.
+func (r *Run) resolveForks(ctx context.Context, cfg config.Config, c client.Client, repos []client.Repository) {
+	resolver, ok := c.(client.ForkResolver)
+	if !ok {
+		return
+	}
+
+	for i, repo := range repos {
+		if !repo.Fork || repo.ForkOf != "" || repo.Container {
+			continue
+		}
+
+		release, err := r.syncer.throttle.API(ctx)
+		if err != nil {
+			return
+		}
+		err = withTimeout(ctx, cfg.Timeouts.API, func(ctx context.Context) error {
+			var err error
+			repos[i].ForkOf, err = resolver.ForkOf(ctx, repo)
+			return err
+		})
+		release()
+		if err != nil {
+			r.log.Warnf("Failed to find the parent of fork %s, it does not share objects: %v", repo.FullName(), err)
+		}
+	}
+}
+
+// lockPool serializes the changes to an object pool and returns the function
+// that ends them
+func (s *Syncer) lockPool(pool string) func() {
+	s.poolsMu.Lock()
+	mu := s.pools[pool]
+	if mu == nil {
+		mu = new(sync.Mutex)
+		s.pools[pool] = mu
+	}
+	s.poolsMu.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// initPool creates the object pool at pool unless it exists
+func initPool(ctx context.Context, config config.Config, pool string) error {
+	if IsRepository(pool) {
+		return nil
+	}
+
+	if _, err := runGit(gitCommand(ctx, config, "init", "--quiet", "--bare", pool)); err != nil {
+		return err
+	}
+	// Garbage collection of its own would drop the objects of members whose
+	// refs were not fetched since they changed
+	for _, setting := range [][2]string{{"gc.auto", "0"}, {"gc.pruneExpire", "never"}, {"maintenance.auto", "false"}} {
+		if _, err := runGit(gitCommand(ctx, config, "--git-dir", pool, "config", setting[0], setting[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinPool makes the repository at repoPath borrow its objects from pool. The
+// objects of the repository are moved into the pool and the copies it keeps
+// of the ones the pool has are dropped.
+func (r *Run) joinPool(ctx context.Context, config config.Config, repoName, repoPath, pool string) error {
+	unlock := r.syncer.lockPool(pool)
+	defer unlock()
+
+	if err := initPool(ctx, config, pool); err != nil {
+		return err
+	}
+
+	gitDir := gitDirOf(repoPath)
+	if err := addAlternate(gitDir, pool); err != nil {
+		return err
+	}
+
+	if err := fetchMember(ctx, config, pool, repoName, gitDir); err != nil {
+		return err
+	}
+
+	local, err := localObjects(ctx, config, gitDir)
+	if err != nil || local == 0 {
+		return err
+	}
+	r.log.Debugf("Moving %d objects of %s into the object pool %s", local, repoName, pool)
+	_, err = runGit(gitCommand(ctx, config, "--git-dir", gitDir, "repack", "-a", "-d", "-l", "-q"))
+	return err
+}
+
+// leavePool copies the objects the repository at repoPath borrows from an
+// object pool back into it, once forks no longer share their objects
+func (r *Run) leavePool(ctx context.Context, config config.Config, repoName, repoPath string) error {
+	gitDir := gitDirOf(repoPath)
+	alternates := filepath.Join(gitDir, "objects", "info", "alternates")
+	content, err := os.ReadFile(alternates)
+	if err != nil {
+		// Repositories that never joined a pool have no alternates
+		return nil
+	}
+	if !bytes.Contains(content, []byte(poolDirName)) {
+		return nil
+	}
+
+	r.log.Infof("Copying the objects of %s out of its object pool", repoName)
+	if _, err := runGit(gitCommand(ctx, config, "--git-dir", gitDir, "repack", "-a", "-d", "-q")); err != nil {
+		return err
+	}
+	if err := os.Remove(alternates); err != nil {
+		return err
+	}
+
+	// Maintenance drops the member should this fail
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); strings.Contains(line, poolDirName) {
+			pool := filepath.Dir(line)
+			unlock := r.syncer.lockPool(pool)
+			err := dropMember(ctx, config, pool, repoName)
+			unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fetchMember fetches the refs of the member repository at gitDir into pool,
+// under refs/members/<name>, and records where the member is kept. The refs
+// keep everything the member borrows reachable in the pool. The objects are
+// kept packed, as only the packed ones are dropped from the member.
+func fetchMember(ctx context.Context, config config.Config, pool, name, gitDir string) error {
+	gitDir, err := filepath.Abs(gitDir)
+	if err != nil {
+		return err
+	}
+
+	refspec := fmt.Sprintf("+refs/*:refs/members/%s/*", name)
+	fetch := gitCommand(ctx, config, "-c", "fetch.unpackLimit=1", "--git-dir", pool, "fetch", "--quiet", "--no-tags", "--prune", gitDir, refspec)
+	if _, err := runGit(fetch); err != nil {
+		return err
+	}
+	_, err = runGit(gitCommand(ctx, config, "--git-dir", pool, "config", memberKey(name), gitDir))
+	return err
+}
+
+// dropMember deletes the refs of the member name from pool, along with the
+// record of where it is kept, so pruning the pool drops what only the member
+// needed
+func dropMember(ctx context.Context, config config.Config, pool, name string) error {
+	refs, err := runGit(gitCommand(ctx, config, "--git-dir", pool, "for-each-ref", "--format=delete %(refname)", "refs/members/"+name+"/"))
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(refs)) > 0 {
+		deleteRefs := gitCommand(ctx, config, "--git-dir", pool, "update-ref", "--stdin")
+		deleteRefs.Stdin = bytes.NewReader(refs)
+		if _, err := runGit(deleteRefs); err != nil {
+			return err
+		}
+	}
+
+	members, err := poolMembers(ctx, config, pool)
+	if err != nil {
+		return err
+	}
+	if _, ok := members[name]; !ok {
+		return nil
+	}
+	_, err = runGit(gitCommand(ctx, config, "--git-dir", pool, "config", "--unset-all", memberKey(name)))
+	return err
+}
+
+// poolMembers returns the git directories of the members of pool by name
+func poolMembers(ctx context.Context, config config.Config, pool string) (map[string]string, error) {
+	output, err := runGit(gitCommand(ctx, config, "--git-dir", pool, "config", "--get-regexp", `^member\..*\.path$`))
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// No member is recorded
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, gitDir, ok := strings.Cut(scanner.Text(), " ")
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "member."), ".path")
+		if ok && name != key {
+			members[name] = gitDir
+		}
+	}
+	return members, nil
+}
+
+// memberKey returns the config key of pool the git directory of the member
+// name is recorded under
+func memberKey(name string) string {
+	return "member." + name + ".path"
+}
+
+// prunePool fetches the refs of the members of pool again, drops the members
+// that no longer borrow objects from it and then garbage collects the objects
+// none of the others can reach. It must not run alongside a sync.
+func prunePool(ctx context.Context, cfg config.Config, pool string) error {
+	pool, err := filepath.Abs(pool)
+	if err != nil {
+		return err
+	}
+	members, err := poolMembers(ctx, cfg, pool)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(members)) {
+		gitDir := members[name]
+		if !borrowsFrom(gitDir, pool) {
+			logger.Infof("Dropping %s from the object pool %s, it no longer shares objects", name, pool)
+			if err := dropMember(ctx, cfg, pool, name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fetchMember(ctx, cfg, pool, name, gitDir); err != nil {
+			return fmt.Errorf("failed to fetch the refs of %s: %w", name, err)
+		}
+	}
+
+	if cfg.Maintenance.PruneExpire == "never" {
+		return nil
+	}
+	// The objects of the pool are packed, which prune alone leaves alone
+	_, err = runGit(gitCommand(ctx, cfg, "--git-dir", pool, "gc", "--quiet", "--prune="+cfg.Maintenance.PruneExpire))
+	return err
+}
+
+// borrowsFrom reports whether the repository at gitDir exists and borrows
+// objects from pool
+func borrowsFrom(gitDir, pool string) bool {
+	content, err := os.ReadFile(filepath.Join(gitDir, "objects", "info", "alternates"))
+	if err != nil {
+		return false
+	}
+	objects := filepath.Join(pool, "objects")
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == objects {
+			return true
+		}
+	}
+	return false
+}
+
+// addAlternate lets the repository at gitDir borrow objects from pool,
+// unless it does already
+func addAlternate(gitDir, pool string) error {
+	alternates := filepath.Join(gitDir, "objects", "info", "alternates")
+	objects := filepath.Join(pool, "objects")
+
+	content, err := os.ReadFile(alternates)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == objects {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(alternates), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(alternates, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, objects); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// localObjects returns how many objects the repository at gitDir stores
+// itself, loose or packed
+func localObjects(ctx context.Context, config config.Config, gitDir string) (int, error) {
+	output, err := runGit(gitCommand(ctx, config, "--git-dir", gitDir, "count-objects", "-v"))
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), ": ")
+		if key == "count" || key == "in-pack" {
+			n, _ := strconv.Atoi(value)
+			total += n
+		}
+	}
+	return total, nil
+}
+
+// gitDirOf returns the git directory of the repository at repoPath, which is
+// repoPath itself unless the repository has a worktree
+func gitDirOf(repoPath string) string {
+	if dotGit := filepath.Join(repoPath, ".git"); IsRepository(dotGit) {
+		return dotGit
+	}
+	return repoPath
+}
+
+// withReference makes a clone command borrow the objects the object pool at
+// pool already has instead of downloading them, unless pool is empty
+func withReference(command *exec.Cmd, pool string) *exec.Cmd {
+	if pool == "" {
+		return command
+	}
+	for i, arg := range command.Args {
+		if arg == "clone" {
+			args := append([]string{}, command.Args[:i+1]...)
+			args = append(args, "--reference-if-able", pool)
+			command.Args = append(args, command.Args[i+1:]...)
+			break
+		}
+	}
+	return command
+}
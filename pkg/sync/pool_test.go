@@ -0,0 +1,179 @@
+package sync
+
+import (
+	"context"
+	"maps"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+func TestForkNetworks(t *testing.T) {
+	tests := []struct {
+		name  string
+		repos []client.Repository
+		want  map[string]string
+	}{
+		{
+			name: "No forks",
+			repos: []client.Repository{
+				{Owner: "alice", Name: "app"},
+				{Owner: "bob", Name: "lib", Fork: true},
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "Forks of a listed repository",
+			repos: []client.Repository{
+				{Owner: "alice", Name: "app"},
+				{Owner: "bob", Name: "app", Fork: true, ForkOf: "alice/app"},
+				{Owner: "carol", Name: "App", Fork: true, ForkOf: "Alice/App"},
+			},
+			want: map[string]string{"alice/app": "alice/app", "bob/app": "alice/app", "carol/app": "alice/app"},
+		},
+		{
+			name: "Fork of a fork",
+			repos: []client.Repository{
+				{Owner: "carol", Name: "app", Fork: true, ForkOf: "bob/app"},
+				{Owner: "bob", Name: "app", Fork: true, ForkOf: "alice/app"},
+			},
+			want: map[string]string{"alice/app": "alice/app", "bob/app": "alice/app", "carol/app": "alice/app"},
+		},
+		{
+			name: "Cycle",
+			repos: []client.Repository{
+				{Owner: "bob", Name: "app", Fork: true, ForkOf: "alice/app"},
+				{Owner: "alice", Name: "app", Fork: true, ForkOf: "bob/app"},
+				{Owner: "carol", Name: "app", Fork: true, ForkOf: "bob/app"},
+			},
+			want: map[string]string{"alice/app": "alice/app", "bob/app": "alice/app", "carol/app": "alice/app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := forkNetworks(tt.repos); !maps.Equal(got, tt.want) {
+				t.Errorf("forkNetworks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// gitOutput runs git in dir and returns its trimmed output, failing the test
+// when it does not succeed
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// hasObject reports whether the object can be read from the repository at
+// gitDir, borrowed or not
+func hasObject(gitDir, object string) bool {
+	return exec.Command("git", "--git-dir", gitDir, "cat-file", "-e", object+"^{commit}").Run() == nil
+}
+
+func TestPoolMemberLeaves(t *testing.T) {
+	logger.InitLogger("fatal")
+	ctx := context.Background()
+	cfg := config.Config{Maintenance: config.MaintenanceConfig{PruneExpire: "now"}}
+
+	// alice/app is the root of the network, bob/app a fork with a commit of
+	// its own
+	dir := t.TempDir()
+	upstream := filepath.Join(dir, "upstream")
+	fork := filepath.Join(dir, "fork")
+	runTestGit(t, dir, "init", "--quiet", upstream)
+	runTestGit(t, upstream, "commit", "--quiet", "--allow-empty", "-m", "shared")
+	runTestGit(t, dir, "clone", "--quiet", upstream, fork)
+	runTestGit(t, fork, "commit", "--quiet", "--allow-empty", "-m", "fork only")
+	shared := gitOutput(t, upstream, "rev-parse", "HEAD")
+	forkOnly := gitOutput(t, fork, "rev-parse", "HEAD")
+
+	members := map[string]string{
+		"alice/app": filepath.Join(dir, "backup", "alice", "app", "app.git"),
+		"bob/app":   filepath.Join(dir, "backup", "bob", "app", "app.git"),
+	}
+	runTestGit(t, dir, "clone", "--quiet", "--mirror", upstream, members["alice/app"])
+	runTestGit(t, dir, "clone", "--quiet", "--mirror", fork, members["bob/app"])
+
+	pool, err := poolPath(filepath.Join(dir, "backup"), "github", "alice/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	syncer, err := NewSyncer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syncer.Close()
+	r := syncer.newRun("github")
+
+	for _, name := range []string{"alice/app", "bob/app"} {
+		if err := r.joinPool(ctx, cfg, name, members[name], pool); err != nil {
+			t.Fatalf("joinPool(%s) failed: %v", name, err)
+		}
+	}
+	if got, err := poolMembers(ctx, cfg, pool); err != nil || len(got) != 2 {
+		t.Fatalf("poolMembers() = %v, %v, want both members", got, err)
+	}
+	if !hasObject(pool, forkOnly) {
+		t.Fatalf("The pool is missing the commit of bob/app")
+	}
+
+	if err := r.leavePool(ctx, cfg, "bob/app", members["bob/app"]); err != nil {
+		t.Fatalf("leavePool() failed: %v", err)
+	}
+	if err := prunePool(ctx, cfg, pool); err != nil {
+		t.Fatalf("prunePool() failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		gitDir string
+		object string
+		want   bool
+	}{
+		{"Remaining member keeps borrowing", members["alice/app"], shared, true},
+		{"Leaving member has its own copy", members["bob/app"], forkOnly, true},
+		{"Leaving member has the shared commit", members["bob/app"], shared, true},
+		{"Pool keeps what the remaining member needs", pool, shared, true},
+		{"Pool drops what only the leaving member needed", pool, forkOnly, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasObject(tt.gitDir, tt.object); got != tt.want {
+				t.Errorf("hasObject(%s, %s) = %v, want %v", tt.gitDir, tt.object, got, tt.want)
+			}
+		})
+	}
+
+	if borrowsFrom(members["bob/app"], pool) {
+		t.Errorf("bob/app still borrows from the pool")
+	}
+	if refs := gitOutput(t, dir, "--git-dir", pool, "for-each-ref", "refs/members/bob/app/"); refs != "" {
+		t.Errorf("The refs of bob/app were not dropped: %s", refs)
+	}
+	if refs := gitOutput(t, dir, "--git-dir", pool, "for-each-ref", "refs/members/alice/app/"); refs == "" {
+		t.Errorf("The refs of alice/app were dropped")
+	}
+	got, err := poolMembers(ctx, cfg, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["bob/app"]; ok || len(got) != 1 {
+		t.Errorf("poolMembers() = %v, want only alice/app", got)
+	}
+}
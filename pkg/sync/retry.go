@@ -1,37 +1,114 @@
 package sync
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"time"
 
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
 	"github.com/AkashRajpurohit/git-sync/pkg/config"
 	"github.com/AkashRajpurohit/git-sync/pkg/logger"
 )
 
-func retryOperation(cfg config.Config, operation func() error, operationName string) error {
+// permanentError marks failures that retrying cannot fix, such as a missing
+// repository or rejected credentials
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// isPermanent reports whether retrying err is pointless. Timeouts and errors
+// that are not known to be permanent are retried.
+func isPermanent(err error) bool {
+	var permanentErr *permanentError
+	if errors.As(err, &permanentErr) {
+		return true
+	}
+
+	var statusErr *client.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Permanent()
+	}
+
+	return errors.Is(err, context.Canceled)
+}
+
+// retryDelay returns how long to wait before the attempt following the given
+// one. A wait requested by the platform takes precedence when it is longer.
+func retryDelay(cfg config.RetryConfig, attempt int, err error) time.Duration {
+	delay := time.Duration(cfg.Delay) * time.Second
+	if cfg.Backoff == "exponential" {
+		// Cap the shift so large retry counts cannot overflow
+		delay <<= min(attempt-1, 30)
+	}
+	if maxDelay := time.Duration(cfg.MaxDelay) * time.Second; maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	if cfg.Jitter && delay > 0 {
+		// Wait between half and all of the delay
+		delay = delay/2 + rand.N(delay/2+1)
+	}
+
+	var statusErr *client.StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+		delay = statusErr.RetryAfter
+	}
+	return delay
+}
+
+// retryOperation runs operation until it succeeds, fails permanently or the
+// retries configured in cfg are used up. Nothing is retried once a shutdown is
+// requested.
+func (r *Run) retryOperation(ctx context.Context, cfg config.Config, operation func() error, operationName string) error {
+	return retry(ctx, cfg.Retry, r.log, operation, operationName)
+}
+
+// retry is retryOperation for callers outside of a run, which log to log
+func retry(ctx context.Context, cfg config.RetryConfig, log *logger.Logger, operation func() error, operationName string) error {
 	var lastErr error
+	shutdown := shutdownRequested(ctx)
 
 	// If retry count is 0 or negative, just execute once without retries
-	if cfg.Retry.Count <= 0 {
+	if cfg.Count <= 0 {
 		return operation()
 	}
 
-	for attempt := 1; attempt <= cfg.Retry.Count; attempt++ {
+	for attempt := 1; attempt <= cfg.Count; attempt++ {
 		err := operation()
 		if err == nil {
 			if attempt > 1 {
-				logger.Warnf("Operation %s succeeded after %d attempts", operationName, attempt)
+				log.Warnf("Operation %s succeeded after %d attempts", operationName, attempt)
 			}
 			return nil
 		}
 
+		if isPermanent(err) {
+			log.Debugf("Not retrying %s, the failure is permanent: %v", operationName, err)
+			return err
+		}
+
 		lastErr = err
-		if attempt < cfg.Retry.Count {
-			logger.Warnf("Attempt %d/%d failed for %s: %v. Retrying in %d seconds...",
-				attempt, cfg.Retry.Count, operationName, err, cfg.Retry.Delay)
-			time.Sleep(time.Duration(cfg.Retry.Delay) * time.Second)
+		if attempt < cfg.Count {
+			delay := retryDelay(cfg, attempt, err)
+			log.Warnf("Attempt %d/%d failed for %s: %v. Retrying in %s...",
+				attempt, cfg.Count, operationName, err, delay.Round(time.Second))
+
+			select {
+			case <-shutdown:
+				return fmt.Errorf("interrupted: %w", err)
+			case <-time.After(delay):
+			}
 		}
 	}
 
-	return fmt.Errorf("operation failed after %d attempts: %v", cfg.Retry.Count, lastErr)
+	return fmt.Errorf("operation failed after %d attempts: %w", cfg.Count, lastErr)
 }
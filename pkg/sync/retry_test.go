@@ -0,0 +1,241 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/client"
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.RetryConfig
+		attempt int
+		err     error
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{
+			name:    "Fixed",
+			cfg:     config.RetryConfig{Delay: 5},
+			attempt: 3,
+			wantMin: 5 * time.Second,
+			wantMax: 5 * time.Second,
+		},
+		{
+			name:    "Exponential first attempt",
+			cfg:     config.RetryConfig{Delay: 2, Backoff: "exponential"},
+			attempt: 1,
+			wantMin: 2 * time.Second,
+			wantMax: 2 * time.Second,
+		},
+		{
+			name:    "Exponential doubles",
+			cfg:     config.RetryConfig{Delay: 2, Backoff: "exponential"},
+			attempt: 3,
+			wantMin: 8 * time.Second,
+			wantMax: 8 * time.Second,
+		},
+		{
+			name:    "Exponential capped by max_delay",
+			cfg:     config.RetryConfig{Delay: 2, Backoff: "exponential", MaxDelay: 10},
+			attempt: 5,
+			wantMin: 10 * time.Second,
+			wantMax: 10 * time.Second,
+		},
+		{
+			name:    "Exponential does not overflow",
+			cfg:     config.RetryConfig{Delay: 1, Backoff: "exponential", MaxDelay: 60},
+			attempt: 100,
+			wantMin: 60 * time.Second,
+			wantMax: 60 * time.Second,
+		},
+		{
+			name:    "Jitter waits between half and all of the delay",
+			cfg:     config.RetryConfig{Delay: 10, Jitter: true},
+			attempt: 1,
+			wantMin: 5 * time.Second,
+			wantMax: 10 * time.Second,
+		},
+		{
+			name:    "Longer Retry-After takes precedence",
+			cfg:     config.RetryConfig{Delay: 5},
+			attempt: 1,
+			err:     &client.StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute},
+			wantMin: time.Minute,
+			wantMax: time.Minute,
+		},
+		{
+			name:    "Shorter Retry-After is ignored",
+			cfg:     config.RetryConfig{Delay: 5},
+			attempt: 1,
+			err:     fmt.Errorf("listing failed: %w", &client.StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second}),
+			wantMin: 5 * time.Second,
+			wantMax: 5 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Jitter is random, so check the bounds a number of times
+			for range 100 {
+				got := retryDelay(tt.cfg, tt.attempt, tt.err)
+				if got < tt.wantMin || got > tt.wantMax {
+					t.Fatalf("retryDelay() = %s, want between %s and %s", got, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "Unknown error",
+			err:  errors.New("connection reset"),
+			want: false,
+		},
+		{
+			name: "Permanent error",
+			err:  fmt.Errorf("clone failed: %w", &permanentError{errors.New("repository not found")}),
+			want: true,
+		},
+		{
+			name: "Not found",
+			err:  &client.StatusError{StatusCode: http.StatusNotFound},
+			want: true,
+		},
+		{
+			name: "Forbidden",
+			err:  &client.StatusError{StatusCode: http.StatusForbidden},
+			want: true,
+		},
+		{
+			name: "Rate limited with 403",
+			err:  &client.StatusError{StatusCode: http.StatusForbidden, RetryAfter: time.Minute},
+			want: false,
+		},
+		{
+			name: "Server error",
+			err:  &client.StatusError{StatusCode: http.StatusBadGateway},
+			want: false,
+		},
+		{
+			name: "Timeout",
+			err:  fmt.Errorf("%w after 1s: %w", errTimeout, context.DeadlineExceeded),
+			want: false,
+		},
+		{
+			name: "Cancelled",
+			err:  fmt.Errorf("fetch failed: %w", context.Canceled),
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPermanent(tt.err); got != tt.want {
+				t.Errorf("isPermanent(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunGit(t *testing.T) {
+	logger.InitLogger("fatal")
+
+	tests := []struct {
+		name          string
+		output        string
+		fail          bool
+		wantErr       bool
+		wantPermanent bool
+		wantTimeout   bool
+	}{
+		{
+			name:   "Success",
+			output: "Everything up-to-date",
+		},
+		{
+			name:    "Network failure",
+			output:  "fatal: unable to access 'https://example.com/repo.git/': Could not resolve host: example.com",
+			fail:    true,
+			wantErr: true,
+		},
+		{
+			name:        "Stalled transfer",
+			output:      "error: RPC failed; curl 28 Operation too slow. Less than 1000 bytes/sec transferred the last 300 seconds",
+			fail:        true,
+			wantErr:     true,
+			wantTimeout: true,
+		},
+		{
+			name:          "Repository not found",
+			output:        "remote: Repository not found.\nfatal: repository 'https://example.com/repo.git/' not found",
+			fail:          true,
+			wantErr:       true,
+			wantPermanent: true,
+		},
+		{
+			name:          "Rejected credentials",
+			output:        "fatal: The requested URL returned error: 403",
+			fail:          true,
+			wantErr:       true,
+			wantPermanent: true,
+		},
+		{
+			name:          "Rejected ssh key",
+			output:        "git@example.com: Permission denied (publickey).",
+			fail:          true,
+			wantErr:       true,
+			wantPermanent: true,
+		},
+		{
+			name:    "Access denied by a proxy",
+			output:  "fatal: unable to access 'https://example.com/repo.git/': Received HTTP code 403 from proxy after CONNECT: Access denied",
+			fail:    true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := `printf '%s\n' "$1" >&2`
+			if tt.fail {
+				script += "; exit 128"
+			}
+
+			output, err := runGit(exec.Command("sh", "-c", script, "git", tt.output))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runGit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(output) != tt.output+"\n" {
+				t.Errorf("runGit() output = %q, want %q", output, tt.output+"\n")
+			}
+			if got := isPermanent(err); got != tt.wantPermanent {
+				t.Errorf("isPermanent(%v) = %v, want %v", err, got, tt.wantPermanent)
+			}
+			if got := errors.Is(err, errTimeout); got != tt.wantTimeout {
+				t.Errorf("errors.Is(%v, errTimeout) = %v, want %v", err, got, tt.wantTimeout)
+			}
+			if tt.wantErr {
+				var exitErr *exec.ExitError
+				if !errors.As(err, &exitErr) {
+					t.Errorf("runGit() error %v does not wrap the exit error", err)
+				}
+			}
+		})
+	}
+}
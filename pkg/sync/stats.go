@@ -1,17 +1,18 @@
 package sync
 
 import (
+	"errors"
 	"fmt"
 	"runtime"
 	"sync"
 
-	"github.com/AkashRajpurohit/git-sync/pkg/config"
-	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/helpers"
 	"github.com/AkashRajpurohit/git-sync/pkg/notification"
 	"github.com/AkashRajpurohit/git-sync/pkg/telemetry"
 	"github.com/AkashRajpurohit/git-sync/pkg/version"
 )
 
+// SyncStats counts the outcomes of a single run
 type SyncStats struct {
 	mu            sync.Mutex
 	ReposSuccess  int
@@ -20,97 +21,151 @@ type SyncStats struct {
 	WikisFailed   []string
 	IssuesSuccess int
 	IssuesFailed  []string
+	// TimedOut lists the failures above that ran out of time or stalled
+	TimedOut        []string
+	SkippedTooLarge []string
+	SkippedLowSpace []string
+	DiskUsage       int64
 }
 
-var stats = &SyncStats{}
-
-func recordRepoSuccess() {
+func (stats *SyncStats) recordRepoSuccess() {
 	stats.mu.Lock()
 	defer stats.mu.Unlock()
 	stats.ReposSuccess++
 }
 
-func recordRepoFailure(repoName string, err error) {
+func (stats *SyncStats) recordRepoFailure(repoName string, err error) {
 	stats.mu.Lock()
 	defer stats.mu.Unlock()
 	stats.ReposFailed = append(stats.ReposFailed, fmt.Sprintf("%s (Error: %v)", repoName, err))
+	stats.recordTimeout(repoName, err)
 }
 
-func recordWikiSuccess() {
+func (stats *SyncStats) recordWikiSuccess() {
 	stats.mu.Lock()
 	defer stats.mu.Unlock()
 	stats.WikisSuccess++
 }
 
-func recordWikiFailure(wikiName string, err error) {
+func (stats *SyncStats) recordWikiFailure(wikiName string, err error) {
 	stats.mu.Lock()
 	defer stats.mu.Unlock()
 	stats.WikisFailed = append(stats.WikisFailed, fmt.Sprintf("%s (Error: %v)", wikiName, err))
+	stats.recordTimeout(wikiName, err)
 }
 
-func recordIssuesSuccess() {
+func (stats *SyncStats) recordIssuesSuccess() {
 	stats.mu.Lock()
 	defer stats.mu.Unlock()
 	stats.IssuesSuccess++
 }
 
-func recordIssuesFailure(repoName string, err error) {
+func (stats *SyncStats) recordIssuesFailure(repoName string, err error) {
 	stats.mu.Lock()
 	defer stats.mu.Unlock()
 	stats.IssuesFailed = append(stats.IssuesFailed, fmt.Sprintf("%s (Error: %v)", repoName, err))
+	stats.recordTimeout(repoName, err)
 }
 
-func LogRepoCount(count int, repoType string) {
-	logger.Info("Total ", repoType, " repositories: ", count)
+func (stats *SyncStats) recordTooLarge(repoName string) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.SkippedTooLarge = append(stats.SkippedTooLarge, repoName)
 }
 
-func LogSyncSummary(cfg *config.Config) {
-	logger.Infof("✅ Repositories: %d successfully synced", stats.ReposSuccess)
-	if len(stats.ReposFailed) > 0 {
-		failedRepos := []string{}
-		for _, repo := range stats.ReposFailed {
-			failedRepos = append(failedRepos, repo)
-		}
-
-		logger.Errorf("❌ Failed repositories: %d", len(failedRepos))
-		logger.Errorf("%s", failedRepos)
-	}
+func (stats *SyncStats) recordLowSpace(repoName string) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.SkippedLowSpace = append(stats.SkippedLowSpace, repoName)
+}
 
-	logger.Infof("✅ Wikis: %d successfully synced", stats.WikisSuccess)
-	if len(stats.WikisFailed) > 0 {
-		failedWikis := []string{}
-		for _, wiki := range stats.WikisFailed {
-			failedWikis = append(failedWikis, wiki)
-		}
+func (stats *SyncStats) recordDiskUsage(bytes int64) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.DiskUsage = bytes
+}
 
-		logger.Errorf("❌ Failed wikis: %d", len(failedWikis))
-		logger.Errorf("%s", failedWikis)
+// recordTimeout adds name to the timed out operations if err is a timeout.
+// The caller must hold stats.mu.
+func (stats *SyncStats) recordTimeout(name string, err error) {
+	if errors.Is(err, errTimeout) {
+		stats.TimedOut = append(stats.TimedOut, name)
 	}
+}
 
-	logger.Infof("✅ Issues: %d repositories' issues synced", stats.IssuesSuccess)
-	if len(stats.IssuesFailed) > 0 {
-		failedIssues := []string{}
-		for _, issue := range stats.IssuesFailed {
-			failedIssues = append(failedIssues, issue)
-		}
+func (r *Run) logRepoCount(count int, repoType string) {
+	r.log.Info("Total ", repoType, " repositories: ", count)
+}
 
-		logger.Errorf("❌ Failed issues: %d", len(failedIssues))
-		logger.Errorf("%s", failedIssues)
-	}
+// Summary returns the outcomes counted so far
+func (stats *SyncStats) Summary() *notification.SyncSummary {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
 
-	summary := &notification.SyncSummary{
+	return &notification.SyncSummary{
 		ReposSuccess:  stats.ReposSuccess,
-		ReposFailed:   stats.ReposFailed,
+		ReposFailed:   append([]string(nil), stats.ReposFailed...),
 		WikisSuccess:  stats.WikisSuccess,
-		WikisFailed:   stats.WikisFailed,
+		WikisFailed:   append([]string(nil), stats.WikisFailed...),
 		IssuesSuccess: stats.IssuesSuccess,
-		IssuesFailed:  stats.IssuesFailed,
+		IssuesFailed:  append([]string(nil), stats.IssuesFailed...),
+		TimedOut:      append([]string(nil), stats.TimedOut...),
+
+		SkippedTooLarge: append([]string(nil), stats.SkippedTooLarge...),
+		SkippedLowSpace: append([]string(nil), stats.SkippedLowSpace...),
+		DiskUsage:       stats.DiskUsage,
+	}
+}
+
+// logSummary logs the outcome of the run, sends the notifications and
+// reports it to telemetry
+func (r *Run) logSummary() *notification.SyncSummary {
+	summary := r.stats.Summary()
+
+	r.log.Infof("✅ Repositories: %d successfully synced", summary.ReposSuccess)
+	if len(summary.ReposFailed) > 0 {
+		r.log.Errorf("❌ Failed repositories: %d", len(summary.ReposFailed))
+		r.log.Errorf("%s", summary.ReposFailed)
+	}
+
+	r.log.Infof("✅ Wikis: %d successfully synced", summary.WikisSuccess)
+	if len(summary.WikisFailed) > 0 {
+		r.log.Errorf("❌ Failed wikis: %d", len(summary.WikisFailed))
+		r.log.Errorf("%s", summary.WikisFailed)
+	}
+
+	r.log.Infof("✅ Issues: %d repositories' issues synced", summary.IssuesSuccess)
+	if len(summary.IssuesFailed) > 0 {
+		r.log.Errorf("❌ Failed issues: %d", len(summary.IssuesFailed))
+		r.log.Errorf("%s", summary.IssuesFailed)
 	}
 
-	if err := notification.NotifyAll(&cfg.Notification, summary); err != nil {
-		logger.Errorf("Failed to send notifications: %v", err)
+	if len(summary.TimedOut) > 0 {
+		r.log.Warnf("⏱️ Timed out or stalled: %d", len(summary.TimedOut))
+		r.log.Warnf("%s", summary.TimedOut)
+	}
+
+	if len(summary.SkippedTooLarge) > 0 {
+		r.log.Warnf("📦 Not cloned, larger than max_repo_size: %d", len(summary.SkippedTooLarge))
+		r.log.Warnf("%s", summary.SkippedTooLarge)
+	}
+
+	if len(summary.SkippedLowSpace) > 0 {
+		r.log.Errorf("🚫 Not cloned, free disk space below min_free_space: %d", len(summary.SkippedLowSpace))
+		r.log.Errorf("%s", summary.SkippedLowSpace)
+	}
+
+	if summary.DiskUsage > 0 {
+		r.log.Infof("💾 Disk usage: %s", helpers.FormatSize(summary.DiskUsage))
+	}
+
+	if r.syncer.Notify != nil {
+		if err := r.syncer.Notify(summary); err != nil {
+			r.log.Errorf("Failed to send notifications: %v", err)
+		}
 	}
 
+	cfg := r.syncer.cfg
 	telemetry.CaptureEvent("sync_completed", map[string]interface{}{
 		"platform":       cfg.Platform,
 		"clone_type":     cfg.CloneType,
@@ -118,17 +173,17 @@ func LogSyncSummary(cfg *config.Config) {
 		"include_wiki":   cfg.IncludeWiki,
 		"include_issues": cfg.IncludeIssues,
 		"include_forks":  cfg.IncludeForks,
-		"repos_success":  stats.ReposSuccess,
-		"repos_failed":   len(stats.ReposFailed),
-		"wikis_success":  stats.WikisSuccess,
-		"wikis_failed":   len(stats.WikisFailed),
-		"issues_success": stats.IssuesSuccess,
-		"issues_failed":  len(stats.IssuesFailed),
+		"repos_success":  summary.ReposSuccess,
+		"repos_failed":   len(summary.ReposFailed),
+		"wikis_success":  summary.WikisSuccess,
+		"wikis_failed":   len(summary.WikisFailed),
+		"issues_success": summary.IssuesSuccess,
+		"issues_failed":  len(summary.IssuesFailed),
+		"timed_out":      len(summary.TimedOut),
 		"app_version":    version.Version,
 		"os":             runtime.GOOS,
 		"arch":           runtime.GOARCH,
 	})
 
-	// Reset stats for next sync
-	stats = &SyncStats{}
+	return summary
 }
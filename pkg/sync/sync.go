@@ -1,236 +1,464 @@
 package sync
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/AkashRajpurohit/git-sync/pkg/config"
 	"github.com/AkashRajpurohit/git-sync/pkg/issues"
 	"github.com/AkashRajpurohit/git-sync/pkg/logger"
-	"github.com/AkashRajpurohit/git-sync/pkg/token"
 )
 
-var tokenManager *token.Manager
-
-func InitTokenManager(tokens []string) {
-	tokenManager = token.NewManager(tokens)
-}
+var errWikiNotFound = errors.New("wiki not found")
 
 func getBaseDirectoryPath(repoOwner, repoName string, config config.Config) string {
 	return filepath.Join(config.BackupDir, repoOwner, repoName)
 }
 
-func getGitCloneCommand(CloneType, repoPath, repoURL string) *exec.Cmd {
-	switch CloneType {
+// RepoPath returns where the repository is stored inside the backup directory
+func RepoPath(repoOwner, repoName string, config config.Config) string {
+	return filepath.Join(getBaseDirectoryPath(repoOwner, repoName, config), repoName+".git")
+}
+
+// CheckoutPath returns where the checkout of the default branch of a bare
+// repository is kept, next to the repository
+func CheckoutPath(repoOwner, repoName string, config config.Config) string {
+	return checkoutPath(RepoPath(repoOwner, repoName, config))
+}
+
+// WikiPath returns where the repository wiki is stored inside the backup directory
+func WikiPath(repoOwner, repoName string, config config.Config) string {
+	return filepath.Join(getBaseDirectoryPath(repoOwner, repoName, config), repoName+".wiki.git")
+}
+
+// gitCommand returns a git command that is interrupted when ctx is cancelled,
+// giving git a chance to remove its lock files and partial clones first
+func gitCommand(ctx context.Context, config config.Config, args ...string) *exec.Cmd {
+	command := exec.CommandContext(ctx, "git", args...)
+	if config.Timeouts.LowSpeedLimit > 0 && config.Timeouts.LowSpeedTime > 0 {
+		command.Env = append(os.Environ(),
+			fmt.Sprintf("GIT_HTTP_LOW_SPEED_LIMIT=%d", config.Timeouts.LowSpeedLimit),
+			fmt.Sprintf("GIT_HTTP_LOW_SPEED_TIME=%d", config.Timeouts.LowSpeedTime),
+		)
+	}
+	command.Cancel = func() error {
+		// Interrupts cannot be sent on Windows
+		if err := command.Process.Signal(os.Interrupt); err != nil {
+			return command.Process.Kill()
+		}
+		return nil
+	}
+	// Kill git if it does not exit in time, and stop waiting for transport
+	// helpers like git-remote-https that hold on to the output
+	command.WaitDelay = 10 * time.Second
+	return command
+}
+
+func getGitCloneCommand(ctx context.Context, config config.Config, repoPath, repoURL string) *exec.Cmd {
+	switch config.CloneType {
 	case "bare":
 		logger.Debugf("Cloning repo with bare clone type: %s", repoURL)
-		return exec.Command("git", "clone", "--bare", repoURL, repoPath)
+		return gitCommand(ctx, config, "clone", "--bare", repoURL, repoPath)
 	case "full":
 		logger.Debugf("Cloning repo with full clone type: %s", repoURL)
-		return exec.Command("git", "clone", repoURL, repoPath)
+		return gitCommand(ctx, config, "clone", repoURL, repoPath)
 	case "mirror":
 		logger.Debugf("Cloning repo with mirror clone type: %s", repoURL)
-		return exec.Command("git", "clone", "--mirror", repoURL, repoPath)
+		return gitCommand(ctx, config, "clone", "--mirror", repoURL, repoPath)
 	case "shallow":
 		logger.Debugf("Cloning repo with shallow clone type: %s", repoURL)
-		return exec.Command("git", "clone", "--depth", "1", repoURL, repoPath)
+		return gitCommand(ctx, config, "clone", "--depth", "1", repoURL, repoPath)
+	case "blobless", "partial":
+		logger.Debugf("Cloning repo with %s clone type: %s", config.CloneType, repoURL)
+		return gitCommand(ctx, config, "clone", "--bare", filterFlag(config), repoURL, repoPath)
+	case "shallow-since":
+		logger.Debugf("Cloning repo with shallow-since clone type: %s", repoURL)
+		return gitCommand(ctx, config, "clone", "--bare", shallowSinceFlag(config), repoURL, repoPath)
 	default:
 		logger.Debugf("[Default] Cloning repo with bare clone type: %s", repoURL)
-		return exec.Command("git", "clone", "--bare", repoURL, repoPath)
+		return gitCommand(ctx, config, "clone", "--bare", repoURL, repoPath)
 	}
 }
 
-func getGitFetchCommand(CloneType, repoPath, repoURL string) *exec.Cmd {
-	switch CloneType {
+func getGitFetchCommand(ctx context.Context, config config.Config, repoPath, repoURL string) *exec.Cmd {
+	switch config.CloneType {
 	case "bare":
 		logger.Debugf("Updating repo with bare clone type: %s", repoPath)
-		return exec.Command("git", "--git-dir", repoPath, "fetch", "--prune", repoURL, "+*:*")
+		return gitCommand(ctx, config, "--git-dir", repoPath, "fetch", "--prune", repoURL, "+*:*")
 	case "full":
+		// The worktree is reset to the default branch afterwards, see
+		// checkoutDefaultBranch
 		logger.Debugf("Updating repo with full clone type: %s", repoPath)
-		return exec.Command("git", "-C", repoPath, "pull", "--prune", repoURL)
+		return gitCommand(ctx, config, append([]string{"-C", repoPath, "fetch", "--prune", repoURL}, worktreeRefspecs...)...)
 	case "mirror":
 		logger.Debugf("Updating repo with mirror clone type: %s", repoPath)
-		return exec.Command("git", "-C", repoPath, "fetch", "--prune", repoURL, "+*:*")
+		return gitCommand(ctx, config, "-C", repoPath, "fetch", "--prune", repoURL, "+*:*")
 	case "shallow":
 		logger.Debugf("Updating repo with shallow clone type: %s", repoPath)
-		return exec.Command("git", "-C", repoPath, "pull", "--prune", repoURL)
+		return gitCommand(ctx, config, append([]string{"-C", repoPath, "fetch", "--prune", "--depth", "1", repoURL}, worktreeRefspecs...)...)
+	case "blobless", "partial":
+		// Without the filter git would fetch the blobs the clone left out
+		logger.Debugf("Updating repo with %s clone type: %s", config.CloneType, repoPath)
+		return gitCommand(ctx, config, "--git-dir", repoPath, "fetch", "--prune", filterFlag(config), repoURL, "+*:*")
+	case "shallow-since":
+		logger.Debugf("Updating repo with shallow-since clone type: %s", repoPath)
+		return gitCommand(ctx, config, "--git-dir", repoPath, "fetch", "--prune", shallowSinceFlag(config), repoURL, "+*:*")
 	default:
 		logger.Debugf("[Default] Updating repo with bare clone type: %s", repoPath)
-		return exec.Command("git", "--git-dir", repoPath, "fetch", "--prune", repoURL, "+*:*")
+		return gitCommand(ctx, config, "--git-dir", repoPath, "fetch", "--prune", repoURL, "+*:*")
 	}
 }
 
-func CloneOrUpdateRepo(repoOwner, repoName string, config config.Config) {
-	if tokenManager == nil {
-		InitTokenManager(config.Tokens)
+// filterFlag leaves out all blobs for blobless clones and the ones larger than
+// clone_options.blob_limit for partial clones
+func filterFlag(config config.Config) string {
+	if config.CloneType == "partial" {
+		return "--filter=blob:limit=" + strings.ToLower(config.CloneOptions.BlobLimit)
 	}
+	return "--filter=blob:none"
+}
 
-	repoFullName := fmt.Sprintf("%s/%s", repoOwner, repoName)
-	repoURL := fmt.Sprintf("%s://%s:%s@%s/%s.git", config.Server.Protocol, config.Username, tokenManager.GetNextToken(), config.Server.Domain, repoFullName)
-	repoPath := filepath.Join(getBaseDirectoryPath(repoOwner, repoName, config), repoName+".git")
+// shallowSinceFlag keeps the history after clone_options.shallow_since for
+// shallow-since clones, or only the last commit when it is empty
+func shallowSinceFlag(config config.Config) string {
+	if config.CloneOptions.ShallowSince == "" {
+		return "--depth=1"
+	}
+	return "--shallow-since=" + config.CloneOptions.ShallowSince
+}
 
-	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
-		logger.Info("Cloning repo: ", repoFullName)
+// noCommitsSince is how git refuses a shallow-since clone or fetch of a
+// repository without commits after the date
+var noCommitsSince = []byte("no commits selected for shallow requests")
 
-		err := retryOperation(config, func() error {
-			command := getGitCloneCommand(config.CloneType, repoPath, repoURL)
-			output, err := command.CombinedOutput()
-			logger.Debugf("Output: %s\n", output)
+// lastCommitOnly returns config changed to keep only the last commit when a
+// shallow-since clone or fetch failed for the lack of commits after the date,
+// as it does for repositories nobody pushed to since
+func lastCommitOnly(config config.Config, output []byte, err error) (config.Config, bool) {
+	if err == nil || config.CloneType != "shallow-since" || !bytes.Contains(output, noCommitsSince) {
+		return config, false
+	}
+	config.CloneOptions.ShallowSince = ""
+	return config, true
+}
+
+// clone clones repoURL into repoPath with the clone type of config. Objects
+// the object pool at pool already has are not downloaded again, when pool is
+// set.
+func (r *Run) clone(ctx context.Context, config config.Config, repoPath, repoURL, pool string) error {
+	output, err := r.git(ctx, config.Timeouts.Clone, repoURL, func(ctx context.Context) *exec.Cmd {
+		return withReference(getGitCloneCommand(ctx, config, repoPath, repoURL), pool)
+	})
+	if lastCommit, ok := lastCommitOnly(config, output, err); ok {
+		r.log.Debugf("No commits since %s, cloning the last commit instead", config.CloneOptions.ShallowSince)
+		_, err = r.git(ctx, config.Timeouts.Clone, repoURL, func(ctx context.Context) *exec.Cmd {
+			return withReference(getGitCloneCommand(ctx, lastCommit, repoPath, repoURL), pool)
+		})
+	}
+	return err
+}
+
+// update fetches repoURL into the clone at repoPath and brings its worktree
+// up to date
+func (r *Run) update(ctx context.Context, config config.Config, repoPath, repoURL string) error {
+	output, err := r.git(ctx, config.Timeouts.Fetch, repoURL, func(ctx context.Context) *exec.Cmd {
+		return getGitFetchCommand(ctx, config, repoPath, repoURL)
+	})
+	if lastCommit, ok := lastCommitOnly(config, output, err); ok {
+		r.log.Debugf("No commits since %s, fetching the last commit instead", config.CloneOptions.ShallowSince)
+		_, err = r.git(ctx, config.Timeouts.Fetch, repoURL, func(ctx context.Context) *exec.Cmd {
+			return getGitFetchCommand(ctx, lastCommit, repoPath, repoURL)
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if hasWorktree(config.CloneType) {
+		return r.checkoutDefaultBranch(ctx, config, repoPath, repoURL)
+	}
+	return nil
+}
+
+// completeSync brings what a clone or update of the repository at repoPath
+// leaves out up to date: its objects in the object pool at pool, its LFS
+// objects and its checkout, when they are enabled. Without a pool the objects
+// it borrows from one are copied back into it.
+func (r *Run) completeSync(ctx context.Context, config config.Config, repoName, repoPath, repoURL, pool string) error {
+	if pool != "" {
+		if err := r.joinPool(ctx, config, repoName, repoPath, pool); err != nil {
+			return fmt.Errorf("failed to share objects with the fork network: %w", err)
+		}
+	} else if err := r.leavePool(ctx, config, repoName, repoPath); err != nil {
+		return fmt.Errorf("failed to stop sharing objects with the fork network: %w", err)
+	}
+	if config.IncludeLFS {
+		if err := r.fetchLFS(ctx, config, repoName, repoPath, repoURL); err != nil {
 			return err
-		}, fmt.Sprintf("clone %s", repoFullName))
+		}
+	}
+	if config.Checkout && !hasWorktree(config.CloneType) {
+		return r.refreshCheckout(ctx, config, repoName, repoPath, repoURL)
+	}
+	return nil
+}
+
+// fetchLFS fetches the Git LFS objects of all refs of the clone at repoPath
+// from repoURL
+func (r *Run) fetchLFS(ctx context.Context, config config.Config, repoName, repoPath, repoURL string) error {
+	err := r.retryOperation(ctx, config, func() error {
+		_, err := r.git(ctx, config.Timeouts.Fetch, repoURL, func(ctx context.Context) *exec.Cmd {
+			return gitCommand(ctx, config, "-C", repoPath, "lfs", "fetch", "--all", repoURL)
+		})
+		return err
+	}, fmt.Sprintf("fetch LFS objects of %s", repoName))
+	if err != nil {
+		return fmt.Errorf("failed to fetch LFS objects: %w", err)
+	}
+	return nil
+}
+
+func (r *Run) cloneOrUpdateRepo(ctx context.Context, repoOwner, repoName, pool string, config config.Config) error {
+	repoFullName := fmt.Sprintf("%s/%s", repoOwner, repoName)
+	repoURL := fmt.Sprintf("%s://%s:%s@%s/%s.git", config.Server.Protocol, config.Username, r.syncer.tokenManager.GetNextToken(), config.Server.Domain, repoFullName)
+	return r.cloneOrUpdate(ctx, repoFullName, RepoPath(repoOwner, repoName, config), repoURL, pool, config)
+}
+
+// cloneOrUpdateRepoFromURL is like cloneOrUpdateRepo for platforms whose clone
+// URLs do not follow the <domain>/<owner>/<repo>.git layout. The configured
+// username and the next token are added to the URL as credentials.
+func (r *Run) cloneOrUpdateRepoFromURL(ctx context.Context, repoOwner, repoName, cloneURL, pool string, config config.Config) error {
+	repoFullName := fmt.Sprintf("%s/%s", repoOwner, repoName)
+	repoURL, err := withCredentials(cloneURL, config.Username, r.syncer.tokenManager.GetNextToken())
+	if err != nil {
+		r.log.Errorf("Failed to clone repo %s: %v", repoFullName, err)
+		r.stats.recordRepoFailure(repoFullName, err)
+		return err
+	}
+
+	return r.cloneOrUpdate(ctx, repoFullName, RepoPath(repoOwner, repoName, config), repoURL, pool, config)
+}
+
+// withCredentials adds the credentials to an http(s) clone URL. URLs of other
+// schemes, such as ssh, and URLs that already carry a password are returned
+// unchanged, as are all URLs when no token is configured.
+func withCredentials(cloneURL, username, token string) (string, error) {
+	if token == "" || !strings.HasPrefix(cloneURL, "http://") && !strings.HasPrefix(cloneURL, "https://") {
+		return cloneURL, nil
+	}
+
+	u, err := url.Parse(cloneURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid clone URL: %v", err)
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		return cloneURL, nil
+	}
+	u.User = url.UserPassword(username, token)
+	return u.String(), nil
+}
+
+func (r *Run) cloneOrUpdate(ctx context.Context, repoFullName, repoPath, repoURL, pool string, config config.Config) error {
+	if needsClone(repoPath) {
+		r.log.Info("Cloning repo: ", repoFullName)
+
+		err := cloneAtomically(repoPath, func(tmpPath string) error {
+			return r.retryOperation(ctx, config, func() error {
+				return r.clone(ctx, config, tmpPath, repoURL, pool)
+			}, fmt.Sprintf("clone %s", repoFullName))
+		})
+		if err == nil {
+			err = r.completeSync(ctx, config, repoFullName, repoPath, repoURL, pool)
+		}
 
 		if err != nil {
-			logger.Errorf("Failed to clone repo %s: %v", repoFullName, err)
-			recordRepoFailure(repoFullName, err)
-			return
+			r.log.Errorf("Failed to clone repo %s: %v", repoFullName, err)
+			r.stats.recordRepoFailure(repoFullName, err)
+			return err
 		}
 
-		logger.Info("Cloned repo: ", repoFullName)
-		recordRepoSuccess()
+		r.log.Info("Cloned repo: ", repoFullName)
+		r.stats.recordRepoSuccess()
+		return nil
 	} else {
-		logger.Info("Updating repo: ", repoFullName)
+		r.log.Info("Updating repo: ", repoFullName)
 
-		err := retryOperation(config, func() error {
-			command := getGitFetchCommand(config.CloneType, repoPath, repoURL)
-			output, err := command.CombinedOutput()
-			logger.Debugf("Output: %s\n", output)
-			return err
+		err := r.retryOperation(ctx, config, func() error {
+			return r.update(ctx, config, repoPath, repoURL)
 		}, fmt.Sprintf("update %s", repoFullName))
+		if err == nil {
+			err = r.completeSync(ctx, config, repoFullName, repoPath, repoURL, pool)
+		}
 
 		if err != nil {
-			logger.Errorf("Failed to update repo %s: %v", repoFullName, err)
-			recordRepoFailure(repoFullName, err)
-			return
+			r.log.Errorf("Failed to update repo %s: %v", repoFullName, err)
+			r.stats.recordRepoFailure(repoFullName, err)
+			return err
 		}
 
-		logger.Info("Updated repo: ", repoFullName)
-		recordRepoSuccess()
+		r.log.Info("Updated repo: ", repoFullName)
+		r.stats.recordRepoSuccess()
+		return nil
 	}
 }
 
-func CloneOrUpdateRawRepo(repoOwner, repoName, repoURL string, config config.Config) {
-	repoPath := filepath.Join(getBaseDirectoryPath(repoOwner, repoName, config), repoName+".git")
+func (r *Run) cloneOrUpdateRawRepo(ctx context.Context, repoOwner, repoName, repoURL string, config config.Config) error {
+	repoPath := RepoPath(repoOwner, repoName, config)
 
-	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
-		logger.Info("Cloning raw repo: ", repoURL)
+	if needsClone(repoPath) {
+		r.log.Info("Cloning raw repo: ", repoURL)
 
-		err := retryOperation(config, func() error {
-			command := getGitCloneCommand(config.CloneType, repoPath, repoURL)
-			output, err := command.CombinedOutput()
-			logger.Debugf("Output: %s\n", output)
-			return err
-		}, fmt.Sprintf("clone %s", repoURL))
+		err := cloneAtomically(repoPath, func(tmpPath string) error {
+			return r.retryOperation(ctx, config, func() error {
+				return r.clone(ctx, config, tmpPath, repoURL, "")
+			}, fmt.Sprintf("clone %s", repoURL))
+		})
+		if err == nil {
+			err = r.completeSync(ctx, config, repoURL, repoPath, repoURL, "")
+		}
 
 		if err != nil {
-			logger.Errorf("Failed to clone raw repo %s: %v", repoURL, err)
-			recordRepoFailure(repoURL, err)
-			return
+			r.log.Errorf("Failed to clone raw repo %s: %v", repoURL, err)
+			r.stats.recordRepoFailure(repoURL, err)
+			return err
 		}
 
-		logger.Info("Cloned raw repo: ", repoURL)
-		recordRepoSuccess()
+		r.log.Info("Cloned raw repo: ", repoURL)
+		r.stats.recordRepoSuccess()
+		return nil
 	} else {
-		logger.Info("Updating raw repo: ", repoURL)
+		r.log.Info("Updating raw repo: ", repoURL)
 
-		err := retryOperation(config, func() error {
-			command := getGitFetchCommand(config.CloneType, repoPath, repoURL)
-			output, err := command.CombinedOutput()
-			logger.Debugf("Output: %s\n", output)
-			return err
+		err := r.retryOperation(ctx, config, func() error {
+			return r.update(ctx, config, repoPath, repoURL)
 		}, fmt.Sprintf("update %s", repoURL))
+		if err == nil {
+			err = r.completeSync(ctx, config, repoURL, repoPath, repoURL, "")
+		}
 
 		if err != nil {
-			logger.Errorf("Failed to update raw repo %s: %v", repoURL, err)
-			recordRepoFailure(repoURL, err)
-			return
+			r.log.Errorf("Failed to update raw repo %s: %v", repoURL, err)
+			r.stats.recordRepoFailure(repoURL, err)
+			return err
 		}
 
-		logger.Info("Updated raw repo: ", repoURL)
-		recordRepoSuccess()
+		r.log.Info("Updated raw repo: ", repoURL)
+		r.stats.recordRepoSuccess()
+		return nil
 	}
 }
 
-func SyncWiki(repoOwner, repoName string, config config.Config) {
-	if tokenManager == nil {
-		InitTokenManager(config.Tokens)
-	}
-
+func (r *Run) syncWiki(ctx context.Context, repoOwner, repoName string, config config.Config) error {
 	repoFullName := fmt.Sprintf("%s/%s", repoOwner, repoName)
-	repoWikiURL := fmt.Sprintf("%s://%s:%s@%s/%s.wiki.git", config.Server.Protocol, config.Username, tokenManager.GetNextToken(), config.Server.Domain, repoFullName)
-	repoWikiPath := filepath.Join(getBaseDirectoryPath(repoOwner, repoName, config), repoName+".wiki.git")
+	repoWikiURL := fmt.Sprintf("%s://%s:%s@%s/%s.wiki.git", config.Server.Protocol, config.Username, r.syncer.tokenManager.GetNextToken(), config.Server.Domain, repoFullName)
+	repoWikiPath := WikiPath(repoOwner, repoName, config)
 
 	// Special handling for bitbucket since it does not follow the traditional pattern for wiki repos
 	// @see here: https://support.atlassian.com/bitbucket-cloud/docs/clone-a-wiki/
 	if config.Platform == "bitbucket" {
-		repoWikiURL = fmt.Sprintf("%s://%s:%s@%s/%s.git/wiki", config.Server.Protocol, config.Username, tokenManager.GetNextToken(), config.Server.Domain, repoFullName)
+		repoWikiURL = fmt.Sprintf("%s://%s:%s@%s/%s.git/wiki", config.Server.Protocol, config.Username, r.syncer.tokenManager.GetNextToken(), config.Server.Domain, repoFullName)
+	}
+
+	return r.cloneOrUpdateWiki(ctx, repoFullName, repoWikiPath, repoWikiURL, config)
+}
+
+// syncWikiFromURL is like syncWiki for platforms whose wikis are separate git
+// repositories with their own clone URL.
+func (r *Run) syncWikiFromURL(ctx context.Context, repoOwner, repoName, wikiURL string, config config.Config) error {
+	repoFullName := fmt.Sprintf("%s/%s", repoOwner, repoName)
+	repoWikiURL, err := withCredentials(wikiURL, config.Username, r.syncer.tokenManager.GetNextToken())
+	if err != nil {
+		r.log.Errorf("Failed to clone wiki %s: %v", repoFullName, err)
+		r.stats.recordWikiFailure(repoFullName, err)
+		return err
 	}
 
-	if _, err := os.Stat(repoWikiPath); os.IsNotExist(err) {
-		logger.Info("Cloning wiki: ", repoFullName)
+	return r.cloneOrUpdateWiki(ctx, repoFullName, WikiPath(repoOwner, repoName, config), repoWikiURL, config)
+}
+
+func (r *Run) cloneOrUpdateWiki(ctx context.Context, repoFullName, repoWikiPath, repoWikiURL string, config config.Config) error {
+	if needsClone(repoWikiPath) {
+		r.log.Info("Cloning wiki: ", repoFullName)
 		wikiNotFound := false
 
-		err := retryOperation(config, func() error {
-			command := exec.Command("git", "clone", repoWikiURL, repoWikiPath)
-			output, err := command.CombinedOutput()
-			logger.Debugf("Output: %s\n", output)
-			if err != nil && strings.Contains(string(output), "not found") {
-				wikiNotFound = true
-				// Don't retry for non-existent wikis
-				return nil
+		err := cloneAtomically(repoWikiPath, func(tmpPath string) error {
+			err := r.retryOperation(ctx, config, func() error {
+				output, err := r.git(ctx, config.Timeouts.Wiki, repoWikiURL, func(ctx context.Context) *exec.Cmd {
+					return gitCommand(ctx, config, "clone", repoWikiURL, tmpPath)
+				})
+				if err != nil && strings.Contains(string(output), "not found") {
+					wikiNotFound = true
+					// Don't retry for non-existent wikis
+					return nil
+				}
+				return err
+			}, fmt.Sprintf("clone wiki %s", repoFullName))
+			if err == nil && wikiNotFound {
+				// Nothing was cloned, so there is nothing to move into place
+				return errWikiNotFound
 			}
 			return err
-		}, fmt.Sprintf("clone wiki %s", repoFullName))
+		})
 
 		if err != nil && !wikiNotFound {
-			logger.Errorf("Failed to clone wiki %s: %v", repoFullName, err)
-			recordWikiFailure(repoFullName, err)
-			return
+			r.log.Errorf("Failed to clone wiki %s: %v", repoFullName, err)
+			r.stats.recordWikiFailure(repoFullName, err)
+			return err
 		}
 
 		if wikiNotFound {
-			logger.Warnf("The wiki for repository %s does not exist. Please check your repository settings and make sure that either wiki is disabled if it is not being used or create a wiki page to start with.", repoFullName)
-		} else {
-			logger.Info("Cloned wiki: ", repoFullName)
-			recordWikiSuccess()
+			r.log.Warnf("The wiki for repository %s does not exist. Please check your repository settings and make sure that either wiki is disabled if it is not being used or create a wiki page to start with.", repoFullName)
+			return nil
 		}
+
+		r.log.Info("Cloned wiki: ", repoFullName)
+		r.stats.recordWikiSuccess()
+		return nil
 	} else {
-		logger.Info("Updating wiki: ", repoFullName)
+		r.log.Info("Updating wiki: ", repoFullName)
 
-		err := retryOperation(config, func() error {
-			command := exec.Command("git", "-C", repoWikiPath, "pull", "--prune", "origin")
-			output, err := command.CombinedOutput()
-			logger.Debugf("Output: %s\n", output)
+		err := r.retryOperation(ctx, config, func() error {
+			_, err := r.git(ctx, config.Timeouts.Wiki, repoWikiURL, func(ctx context.Context) *exec.Cmd {
+				return gitCommand(ctx, config, "-C", repoWikiPath, "pull", "--prune", "origin")
+			})
 			return err
 		}, fmt.Sprintf("update wiki %s", repoFullName))
 
 		if err != nil {
-			logger.Errorf("Failed to update wiki %s: %v", repoFullName, err)
-			recordWikiFailure(repoFullName, err)
-			return
+			r.log.Errorf("Failed to update wiki %s: %v", repoFullName, err)
+			r.stats.recordWikiFailure(repoFullName, err)
+			return err
 		}
 
-		logger.Info("Updated wiki: ", repoFullName)
-		recordWikiSuccess()
+		r.log.Info("Updated wiki: ", repoFullName)
+		r.stats.recordWikiSuccess()
+		return nil
 	}
 }
 
-func SyncIssues(repoOwner, repoName string, allIssues []issues.Issue, cfg config.Config) {
+func (r *Run) syncIssues(ctx context.Context, repoOwner, repoName string, allIssues []issues.Issue, cfg config.Config) error {
 	repoFullName := fmt.Sprintf("%s/%s", repoOwner, repoName)
-	logger.Info("Syncing issues for: ", repoFullName)
+	r.log.Info("Syncing issues for: ", repoFullName)
 
-	err := retryOperation(cfg, func() error {
+	err := r.retryOperation(ctx, cfg, func() error {
 		return issues.WriteIssues(cfg.BackupDir, repoOwner, repoName, allIssues)
 	}, fmt.Sprintf("sync issues %s", repoFullName))
 
 	if err != nil {
-		logger.Errorf("Failed to sync issues for %s: %v", repoFullName, err)
-		recordIssuesFailure(repoFullName, err)
-		return
+		r.log.Errorf("Failed to sync issues for %s: %v", repoFullName, err)
+		r.stats.recordIssuesFailure(repoFullName, err)
+		return err
 	}
 
-	logger.Infof("Synced %d issues for %s", len(allIssues), repoFullName)
-	recordIssuesSuccess()
+	r.log.Infof("Synced %d issues for %s", len(allIssues), repoFullName)
+	r.stats.recordIssuesSuccess()
+	return nil
 }
@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/filter"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"github.com/AkashRajpurohit/git-sync/pkg/notification"
+	"github.com/AkashRajpurohit/git-sync/pkg/throttle"
+	"github.com/AkashRajpurohit/git-sync/pkg/token"
+)
+
+// Syncer backs up the repositories of the sources it is asked to sync with a
+// single configuration. It owns the token manager clone URLs are
+// authenticated with, the throttle enforcing the configured limits and the
+// per-repository overrides, so Syncers for different configurations can run
+// side by side.
+type Syncer struct {
+	cfg          config.Config
+	tokenManager *token.Manager
+	throttle     *throttle.Throttle
+	overrides    *filter.Overrides
+
+	// pools holds a lock per object pool, taken while a fork joins it
+	pools   map[string]*sync.Mutex
+	poolsMu sync.Mutex
+
+	// Notify is called with the summary of every run. It sends the
+	// notifications configured in cfg by default and may be nil.
+	Notify func(summary *notification.SyncSummary) error
+}
+
+// NewSyncer returns a Syncer for cfg. It must be closed once it is no longer
+// used.
+func NewSyncer(cfg config.Config) (*Syncer, error) {
+	overrides, err := filter.CompileOverrides(cfg.Overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := throttle.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Syncer{
+		cfg:          cfg,
+		tokenManager: token.NewManager(cfg.Tokens),
+		throttle:     t,
+		overrides:    overrides,
+		pools:        make(map[string]*sync.Mutex),
+		Notify: func(summary *notification.SyncSummary) error {
+			return notification.NotifyAll(&cfg.Notification, summary)
+		},
+	}, nil
+}
+
+// Close releases the resources of the Syncer
+func (s *Syncer) Close() error {
+	return s.throttle.Close()
+}
+
+// Run is a single sync of one source. It collects the statistics its summary
+// is made of and logs with the name of the source.
+type Run struct {
+	syncer *Syncer
+	log    *logger.Logger
+	stats  SyncStats
+}
+
+func (s *Syncer) newRun(name string) *Run {
+	return &Run{
+		syncer: s,
+		log:    logger.With("source", name),
+	}
+}
+
+// git runs the command returned by build once a git slot for remote is free,
+// within timeout seconds of getting the slot
+func (r *Run) git(ctx context.Context, timeout int, remote string, build func(ctx context.Context) *exec.Cmd) ([]byte, error) {
+	release, err := r.syncer.throttle.Git(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var output []byte
+	err = withTimeout(ctx, timeout, func(ctx context.Context) error {
+		command := build(ctx)
+		r.syncer.throttle.Apply(command, remote)
+		output, err = runGit(command)
+		return err
+	})
+	return output, err
+}
@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+// errTimeout marks operations that ran out of time or stalled, so they can be
+// told apart from other failures in the summary
+var errTimeout = errors.New("timed out")
+
+// withTimeout runs operation with a context that expires after the given
+// number of seconds, or never when it is 0. An operation that runs out of
+// time returns an error wrapping errTimeout.
+func withTimeout(ctx context.Context, seconds int, operation func(ctx context.Context) error) error {
+	if seconds <= 0 {
+		return operation(ctx)
+	}
+
+	timeout := time.Duration(seconds) * time.Second
+	opCtx, cancel := context.WithTimeoutCause(ctx, timeout, errTimeout)
+	defer cancel()
+
+	err := operation(opCtx)
+	if err != nil && !errors.Is(err, errTimeout) && context.Cause(opCtx) == errTimeout {
+		return fmt.Errorf("%w after %s: %w", errTimeout, timeout, err)
+	}
+	return err
+}
+
+// permanentGitErrors are messages of git failures that retrying cannot fix
+var permanentGitErrors = [][]byte{
+	[]byte("repository not found"),
+	[]byte("does not appear to be a git repository"),
+	[]byte("authentication failed"),
+	[]byte("could not read username"),
+	[]byte("invalid username or password"),
+	[]byte("permission denied (publickey"), // ssh rejected the key
+	[]byte("error: permission to "),        // such as "ERROR: Permission to owner/repo.git denied to user."
+	[]byte("remote: permission to "),
+	[]byte("remote: access denied"), // the server refused, not a proxy or SSO page in between
+	[]byte("remote error: access denied"),
+	[]byte("the requested url returned error: 401"),
+	[]byte("the requested url returned error: 403"),
+	[]byte("the requested url returned error: 404"),
+	[]byte("is not a git command"), // such as lfs when git-lfs is not installed
+}
+
+// runGit runs a git command and logs its output. Transfers aborted for being
+// slower than the low speed limit are reported as timeouts, and failures that
+// retrying cannot fix as permanent.
+func runGit(command *exec.Cmd) ([]byte, error) {
+	output, err := command.CombinedOutput()
+	logger.Debugf("Output: %s\n", output)
+	if err == nil {
+		return output, nil
+	}
+
+	if bytes.Contains(output, []byte("Operation too slow")) {
+		return output, fmt.Errorf("%w: transfer stalled: %w", errTimeout, err)
+	}
+
+	lower := bytes.ToLower(output)
+	for _, message := range permanentGitErrors {
+		if bytes.Contains(lower, message) {
+			return output, &permanentError{fmt.Errorf("%w: %s", err, message)}
+		}
+	}
+	return output, err
+}
@@ -0,0 +1,270 @@
+package throttle
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+	"golang.org/x/time/rate"
+)
+
+// proxy is a forward proxy on the loopback interface that git transfers are
+// routed through to share a bandwidth limit. https is tunneled with CONNECT,
+// so the transfers stay encrypted end to end. Requests go on through the
+// proxy git would have used itself, if any.
+type proxy struct {
+	listener  net.Listener
+	server    *http.Server
+	transport *http.Transport
+	upstream  func(*http.Request) (*url.URL, error)
+	bandwidth func() int // in KiB per second, 0 or less for no limit
+
+	mu      sync.Mutex
+	limiter *rate.Limiter
+}
+
+func newProxy(bandwidth func() int, upstream func(*http.Request) (*url.URL, error)) (*proxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &proxy{
+		listener:  listener,
+		transport: &http.Transport{Proxy: upstream},
+		upstream:  upstream,
+		bandwidth: bandwidth,
+		limiter:   rate.NewLimiter(rate.Inf, 0),
+	}
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: 30 * time.Second}
+	go p.server.Serve(listener)
+
+	return p, nil
+}
+
+// upstreamProxy returns the proxy git uses without the bandwidth limit: its
+// http.proxy setting, or else the proxy environment variables. ok is false for
+// proxies requests cannot be sent on to, such as socks ones.
+func upstreamProxy() (upstream func(*http.Request) (*url.URL, error), ok bool, err error) {
+	output, err := exec.Command("git", "config", "--get", "http.proxy").Output()
+	configured := strings.TrimSpace(string(output))
+	if err != nil || configured == "" {
+		return http.ProxyFromEnvironment, true, nil
+	}
+
+	if !strings.Contains(configured, "://") {
+		configured = "http://" + configured
+	}
+	u, err := url.Parse(configured)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid http.proxy %q: %v", configured, err)
+	}
+	if u.Scheme != "http" {
+		return nil, false, nil
+	}
+	if u.Port() == "" {
+		// The default port of git, not of http
+		u.Host = net.JoinHostPort(u.Hostname(), "1080")
+	}
+	return http.ProxyURL(u), true, nil
+}
+
+// URL returns the address to configure as the proxy of git
+func (p *proxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+func (p *proxy) Close() error {
+	p.transport.CloseIdleConnections()
+	return p.server.Close()
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	p.forward(w, r)
+}
+
+// tunnel connects the client to the host it asked for and copies the
+// encrypted stream in both directions
+func (p *proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := p.dial(r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+	client, _, err := hijacker.Hijack()
+	if err != nil {
+		logger.Debugf("Failed to hijack proxy connection: %v", err)
+		return
+	}
+	defer client.Close()
+
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.copy(upstream, client)
+		// Let the server see the end of the request
+		if tcp, ok := upstream.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		close(done)
+	}()
+	p.copy(client, upstream)
+	client.Close()
+	<-done
+}
+
+// dial connects to host, through a tunnel of the upstream proxy when there is
+// one for it
+func (p *proxy) dial(host string) (net.Conn, error) {
+	via, err := p.upstream(&http.Request{URL: &url.URL{Scheme: "https", Host: host}})
+	if err != nil {
+		return nil, err
+	}
+	if via == nil {
+		return net.DialTimeout("tcp", host, 30*time.Second)
+	}
+	if via.Scheme != "http" {
+		return nil, fmt.Errorf("cannot tunnel through the %s proxy %s", via.Scheme, via.Redacted())
+	}
+
+	address := via.Host
+	if via.Port() == "" {
+		address = net.JoinHostPort(via.Hostname(), "80")
+	}
+	conn, err := net.DialTimeout("tcp", address, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	connect := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: host},
+		Host:   host,
+		Header: make(http.Header),
+	}
+	if via.User != nil {
+		password, _ := via.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(via.User.Username() + ":" + password))
+		connect.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	if err := connect.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, connect)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", via.Redacted(), host, response.Status)
+	}
+	// The tunnel is silent until the client speaks first
+	if reader.Buffered() > 0 {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s sent data before the tunnel to %s was used", via.Redacted(), host)
+	}
+	conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+// forward sends a plain http request on to its host
+func (p *proxy) forward(w http.ResponseWriter, r *http.Request) {
+	request := r.Clone(r.Context())
+	request.RequestURI = ""
+	request.Header.Del("Proxy-Connection")
+	request.Header.Del("Proxy-Authorization")
+	if r.ContentLength != 0 {
+		request.Body = io.NopCloser(readerFunc(func(b []byte) (int, error) { return p.read(r.Body, b) }))
+	}
+
+	response, err := p.transport.RoundTrip(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+
+	for key, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+	p.copy(w, response.Body)
+}
+
+// copy copies src to dst within the bandwidth limit
+func (p *proxy) copy(dst io.Writer, src io.Reader) {
+	io.CopyBuffer(dst, readerFunc(func(b []byte) (int, error) { return p.read(src, b) }), make([]byte, 32*1024))
+}
+
+// read reads from src no more than the limit allows right now, and waits
+// until the bytes read may pass
+func (p *proxy) read(src io.Reader, b []byte) (int, error) {
+	limiter := p.currentLimiter()
+	if limiter.Limit() != rate.Inf && len(b) > limiter.Burst() {
+		b = b[:limiter.Burst()]
+	}
+
+	n, err := src.Read(b)
+	if n > 0 && limiter.Limit() != rate.Inf {
+		limiter.WaitN(context.Background(), n)
+	}
+	return n, err
+}
+
+// currentLimiter returns the limiter shared by all transfers, adjusted to the
+// bandwidth in effect
+func (p *proxy) currentLimiter() *rate.Limiter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	limit := rate.Inf
+	burst := 0
+	if kib := p.bandwidth(); kib > 0 {
+		limit = rate.Limit(kib * 1024)
+		burst = kib * 1024
+	}
+	if p.limiter.Limit() != limit {
+		p.limiter.SetLimit(limit)
+		p.limiter.SetBurst(burst)
+	}
+	return p.limiter
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(b []byte) (int, error) {
+	return f(b)
+}
@@ -0,0 +1,54 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// recheckInterval is how often waiters look at the limit again when no slot
+// is released, so limits raised by a schedule take effect
+const recheckInterval = time.Minute
+
+// semaphore limits how many holders run at once. The limit is looked up on
+// every acquire, so it can change while the semaphore is in use.
+type semaphore struct {
+	limit func() int // 0 or less for no limit
+
+	mu       sync.Mutex
+	inUse    int
+	released chan struct{} // closed and replaced whenever a slot is released
+}
+
+func newSemaphore(limit func() int) *semaphore {
+	return &semaphore{limit: limit, released: make(chan struct{})}
+}
+
+// acquire waits for a slot until ctx is done
+func (s *semaphore) acquire(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		if limit := s.limit(); limit <= 0 || s.inUse < limit {
+			s.inUse++
+			s.mu.Unlock()
+			return nil
+		}
+		released := s.released
+		s.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-time.After(recheckInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *semaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inUse--
+	close(s.released)
+	s.released = make(chan struct{})
+}
@@ -0,0 +1,186 @@
+// Package throttle enforces the limits configured under limits, following
+// their schedules as the time of day changes. Limits change with the
+// schedules only, not with how transfers and requests fare.
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AkashRajpurohit/git-sync/pkg/config"
+	"github.com/AkashRajpurohit/git-sync/pkg/logger"
+)
+
+// Throttle hands out the slots git transfers and API requests run in, and
+// routes git transfers through a bandwidth limiting proxy
+type Throttle struct {
+	limits      config.LimitsConfig
+	concurrency int
+	now         func() time.Time
+
+	git   *semaphore
+	api   *semaphore
+	proxy *proxy // nil when no bandwidth limit is configured
+
+	mu    sync.Mutex
+	hosts map[string]*semaphore
+}
+
+// New returns a Throttle for the limits of cfg. Git and API concurrency
+// default to the concurrency of cfg.
+func New(cfg config.Config) (*Throttle, error) {
+	t := &Throttle{
+		limits:      cfg.Limits,
+		concurrency: cfg.Concurrency,
+		now:         time.Now,
+		hosts:       make(map[string]*semaphore),
+	}
+	t.git = newSemaphore(func() int { return t.current().GitConcurrency })
+	t.api = newSemaphore(func() int { return t.current().APIConcurrency })
+
+	if limitsBandwidth(cfg.Limits) {
+		upstream, ok, err := upstreamProxy()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			logger.Warn("Not limiting bandwidth, git is configured with a proxy that transfers cannot be sent on to")
+			return t, nil
+		}
+
+		p, err := newProxy(func() int { return t.current().Bandwidth }, upstream)
+		if err != nil {
+			return nil, err
+		}
+		t.proxy = p
+	}
+
+	return t, nil
+}
+
+// current returns the limits in effect right now
+func (t *Throttle) current() config.Limits {
+	limits := t.limits.At(t.now())
+	if limits.GitConcurrency == 0 {
+		limits.GitConcurrency = t.concurrency
+	}
+	if limits.APIConcurrency == 0 {
+		limits.APIConcurrency = t.concurrency
+	}
+	return limits
+}
+
+// Git waits for a slot to run a git transfer against remote in, and returns
+// the function that gives it back
+func (t *Throttle) Git(ctx context.Context, remote string) (func(), error) {
+	host := t.host(hostOf(remote))
+	if err := host.acquire(ctx); err != nil {
+		return nil, err
+	}
+	if err := t.git.acquire(ctx); err != nil {
+		host.release()
+		return nil, err
+	}
+
+	return func() {
+		t.git.release()
+		host.release()
+	}, nil
+}
+
+// API waits for a slot to run an API request in, and returns the function
+// that gives it back
+func (t *Throttle) API(ctx context.Context) (func(), error) {
+	if err := t.api.acquire(ctx); err != nil {
+		return nil, err
+	}
+	return t.api.release, nil
+}
+
+// Apply routes the transfers of a git command against remote through the
+// bandwidth limiting proxy, which sends them on through the proxy git is
+// configured with. The proxy is set for remote itself, as a proxy configured
+// for a URL takes precedence over http.proxy. Only http(s) remotes use it.
+func (t *Throttle) Apply(command *exec.Cmd, remote string) {
+	if t.proxy == nil {
+		return
+	}
+	u, err := url.Parse(remote)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	// Proxies configured for a URL without a user apply to every user, and
+	// the token stays out of the config
+	u.User = nil
+
+	key := fmt.Sprintf("http.%s.proxy=%s", u, t.proxy.URL())
+	args := append([]string{command.Args[0], "-c", key}, command.Args[1:]...)
+	command.Args = args
+}
+
+// Close stops the bandwidth limiting proxy
+func (t *Throttle) Close() error {
+	if t.proxy == nil {
+		return nil
+	}
+	return t.proxy.Close()
+}
+
+func (t *Throttle) host(name string) *semaphore {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.hosts[name] == nil {
+		if name == "" {
+			// Local repositories are not limited per host
+			t.hosts[name] = newSemaphore(func() int { return 0 })
+		} else {
+			t.hosts[name] = newSemaphore(func() int { return t.current().PerHost })
+		}
+	}
+	return t.hosts[name]
+}
+
+// hostOf returns the host of a clone URL, including scp-like ssh URLs such as
+// git@github.com:owner/repo.git, or "" for local paths
+func hostOf(remote string) string {
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return ""
+		}
+		return strings.ToLower(u.Hostname())
+	}
+
+	colon := strings.Index(remote, ":")
+	if colon < 0 || strings.ContainsAny(remote[:colon], "/\\") {
+		return ""
+	}
+	host := remote[:colon]
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	// Windows drive letters like C:\ are paths, not hosts
+	if len(host) == 1 {
+		return ""
+	}
+	return strings.ToLower(host)
+}
+
+// limitsBandwidth reports whether the bandwidth is limited at any time
+func limitsBandwidth(limits config.LimitsConfig) bool {
+	if limits.Bandwidth > 0 {
+		return true
+	}
+	for _, schedule := range limits.Schedules {
+		if schedule.Bandwidth > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package usage
+
+// Other platforms leave min_free_space unenforced
+
+func freeSpace(path string) (int64, error) {
+	return 0, errUnsupported
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package usage
+
+import "golang.org/x/sys/windows"
+
+func freeSpace(path string) (int64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &available, nil, nil); err != nil {
+		return 0, err
+	}
+	return int64(available), nil
+}
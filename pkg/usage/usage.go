@@ -0,0 +1,123 @@
+// Package usage accounts for the disk space the backups take and the space
+// left on the disk they are on.
+//
+// The usage of every source is measured after each of its syncs and kept in
+// a state file in the backup directory, broken down by owner and repository,
+// so growth can be followed between syncs.
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// FileName is the name of the state file in the backup directory
+const FileName = ".git-sync-usage.json"
+
+// errUnsupported is returned by FreeSpace on platforms it cannot be measured
+// on
+var errUnsupported = errors.New("free space cannot be measured on this platform")
+
+// Usage is the disk usage of a source in bytes
+type Usage struct {
+	UpdatedAt time.Time        `json:"updated_at"`
+	Total     int64            `json:"total"`
+	Pools     int64            `json:"pools,omitempty"` // object pools shared by forks
+	Owners    map[string]int64 `json:"owners"`
+	Repos     map[string]int64 `json:"repos"`
+}
+
+// state is the content of the state file
+type state struct {
+	Sources map[string]*Usage `json:"sources"`
+}
+
+// New returns an empty Usage
+func New() *Usage {
+	return &Usage{Owners: make(map[string]int64), Repos: make(map[string]int64)}
+}
+
+// AddRepo adds the size of the repository owner/name, which is stored in
+// namespace, or outside of a namespace when it is empty
+func (u *Usage) AddRepo(namespace, owner, name string, size int64) {
+	u.Repos[path.Join(namespace, owner, name)] += size
+	u.Owners[path.Join(namespace, owner)] += size
+	u.Total += size
+}
+
+// AddPools adds the size of object pools
+func (u *Usage) AddPools(size int64) {
+	u.Pools += size
+	u.Total += size
+}
+
+// Load returns the usage of every source measured so far. A missing state
+// file has none.
+func Load(backupDir string) (map[string]*Usage, error) {
+	data, err := os.ReadFile(filepath.Join(backupDir, FileName))
+	if os.IsNotExist(err) {
+		return map[string]*Usage{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Sources == nil {
+		s.Sources = map[string]*Usage{}
+	}
+	return s.Sources, nil
+}
+
+// Save records u as the usage of source, keeping that of the other sources.
+// An unreadable state file is replaced.
+func Save(backupDir, source string, u *Usage) error {
+	sources, err := Load(backupDir)
+	if err != nil {
+		sources = map[string]*Usage{}
+	}
+	sources[source] = u
+
+	data, err := json.MarshalIndent(state{Sources: sources}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Replaced at once so a crash never leaves a partial file behind
+	filePath := filepath.Join(backupDir, FileName)
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
+}
+
+// DirSize returns the total size of the files in dir, counting what it can
+// read. A missing dir has none.
+func DirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// FreeSpace returns the bytes available to unprivileged users on the disk
+// holding path
+func FreeSpace(path string) (int64, error) {
+	return freeSpace(path)
+}
@@ -0,0 +1,96 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAddRepo(t *testing.T) {
+	u := New()
+	u.AddRepo("", "alice", "app", 100)
+	u.AddRepo("", "alice", "lib", 50)
+	u.AddRepo("_starred", "alice", "app", 10)
+	u.AddPools(5)
+
+	if u.Total != 165 || u.Pools != 5 {
+		t.Errorf("Expected a total of 165 with 5 in pools, got %d with %d", u.Total, u.Pools)
+	}
+	wantOwners := map[string]int64{"alice": 150, "_starred/alice": 10}
+	if !reflect.DeepEqual(u.Owners, wantOwners) {
+		t.Errorf("Expected owners %v, got %v", wantOwners, u.Owners)
+	}
+	wantRepos := map[string]int64{"alice/app": 100, "alice/lib": 50, "_starred/alice/app": 10}
+	if !reflect.DeepEqual(u.Repos, wantRepos) {
+		t.Errorf("Expected repos %v, got %v", wantRepos, u.Repos)
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+
+	if sources, err := Load(dir); err != nil || len(sources) != 0 {
+		t.Fatalf("Expected no usage without a state file, got %v, %v", sources, err)
+	}
+
+	github := New()
+	github.AddRepo("", "alice", "app", 100)
+	raw := New()
+	raw.AddRepo("", "example.com", "repo", 20)
+	for source, u := range map[string]*Usage{"github": github, "raw": raw} {
+		if err := Save(dir, source, u); err != nil {
+			t.Fatalf("Failed to save the usage of %s: %v", source, err)
+		}
+	}
+
+	sources, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Failed to load the usage: %v", err)
+	}
+	if sources["github"].Total != 100 || sources["raw"].Total != 20 {
+		t.Errorf("Expected the usage of every source to be kept, got %+v", sources)
+	}
+}
+
+func TestSaveReplacesCorruptStateFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Errorf("Expected a corrupt state file to fail loading")
+	}
+
+	if err := Save(dir, "github", New()); err != nil {
+		t.Fatalf("Failed to save the usage: %v", err)
+	}
+	if sources, err := Load(dir); err != nil || sources["github"] == nil {
+		t.Errorf("Expected a corrupt state file to be replaced, got %v, %v", sources, err)
+	}
+}
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{
+		"HEAD": 23,
+		filepath.Join("objects", "pack", "a.pack"): 1000,
+		filepath.Join("refs", "heads", "main"):     41,
+	}
+	for name, size := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := DirSize(dir); got != 1064 {
+		t.Errorf("Expected 1064 bytes, got %d", got)
+	}
+	if got := DirSize(filepath.Join(dir, "missing")); got != 0 {
+		t.Errorf("Expected a missing directory to be empty, got %d", got)
+	}
+}